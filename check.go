@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jeredw/eniacsim/lib/eniac"
+)
+
+// checkConfig loads a configuration file and reports likely wiring mistakes.
+// Returns 0 if none were found, 1 if some were and 2 on error.
+func checkConfig(path string) int {
+	m := eniac.NewMachine(eniac.MachineConn{Output: os.Stderr})
+	if err := m.Load(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if m.Check(os.Stdout) != 0 {
+		return 1
+	}
+	return 0
+}
@@ -100,7 +100,7 @@ func main() {
 	}
 	defer f.Close()
 	fmt.Fprintln(f, "// Generated by gen_permuters.go")
-	fmt.Fprintln(f, "package main\n")
+	fmt.Fprintln(f, "package eniac\n")
 	fmt.Fprintf(f, "import (\n")
 	fmt.Fprintf(f, "\t. \"github.com/jeredw/eniacsim/lib\"\n")
 	fmt.Fprintf(f, ")\n\n")
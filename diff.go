@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jeredw/eniacsim/lib/eniac"
+)
+
+// diffConfigs loads two configuration files and prints how their setups
+// differ.  Returns an exit status like diff(1): 0 if the same, 1 if different
+// and 2 on error.
+func diffConfigs(path1, path2 string) int {
+	var machines [2]*eniac.Machine
+	for i, path := range []string{path1, path2} {
+		machines[i] = eniac.NewMachine(eniac.MachineConn{Output: os.Stderr})
+		if err := machines[i].Load(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if eniac.Diff(os.Stdout, machines[0], machines[1]) != 0 {
+		return 1
+	}
+	return 0
+}
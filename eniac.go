@@ -9,26 +9,19 @@ import (
 	//"net/http"
 	//_ "net/http/pprof"
 
-	. "github.com/jeredw/eniacsim/lib"
-	"github.com/jeredw/eniacsim/lib/units"
+	"github.com/jeredw/eniacsim/lib/eniac"
 )
 
-var cycle *units.Cycle
-var u *units.ClockedUnits
-var printer *units.Printer
-var debugger *Debugger
-var trays *Trays
-var adapters *Adapters
-var pulseAmps *PulseAmps
-
-var ratsNest *RatsNest
-var waves *wavedump
+var machine *eniac.Machine
 
 var vm *VM
 
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] [configuration file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s diff a.e b.e\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s check a.e\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s lockstep [options] a.e b.e\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	useControl := flag.Bool("c", false, "use a portable control station connected to GPIO pins")
@@ -41,14 +34,56 @@ func main() {
 	useTkGui := flag.Bool("T", false, "run tk GUI")
 	quiet := flag.Bool("q", false, "don't print a prompt")
 	vmPath := flag.String("v", "", "path to vm library if any")
+	powerOn := flag.String("power-on", "zero", "initial `state`, zero or random:seed")
+	report := flag.String("report", "", "write assertion results to `file`, as JUnit XML if it ends in .xml or else JSON")
 	flag.Parse()
 
+	if flag.Arg(0) == "diff" {
+		if flag.NArg() != 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(diffConfigs(flag.Arg(1), flag.Arg(2)))
+	}
+	if flag.Arg(0) == "check" {
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(checkConfig(flag.Arg(1)))
+	}
+	if flag.Arg(0) == "lockstep" {
+		os.Exit(lockstep(*powerOn, flag.Args()[1:]))
+	}
+
 	var ppunch chan string
+	if *useTkGui && *useWebGui == "" {
+		ppunch = make(chan string)
+	}
+
+	vm = NewVM(*vmPath)
+	defer vm.Close()
+	// exit ends the run, failing if any assertion did.
+	exit := func() {
+		vm.Close()
+		machine.CloseTraces(os.Stderr)
+		os.Exit(finishRun(machine, *report, flag.Arg(0)))
+	}
+	machine = eniac.NewMachine(eniac.MachineConn{
+		Ppunch:          ppunch,
+		StepAndVerifyVM: func() { vm.StepAndVerify() },
+		StepAheadVM:     func(cycle int64) { vm.StepAhead(cycle) },
+		Quit:            exit,
+	})
+	if err := machine.PowerOn(*powerOn); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	if *useWebGui != "" {
 		go webGui(*useWebGui)
 	} else if *useTkGui {
 		go gui(*demoMode, *tkKludge, *useControl, *width)
-		ppunch = make(chan string)
 	}
 	if *useControl {
 		go ctlstation()
@@ -58,91 +93,31 @@ func main() {
 	//	fmt.Println(http.ListenAndServe("localhost:6060", nil))
 	//}()
 
-	ratsNest = NewRatsNest()
-	trays = NewTrays()
-	adapters = NewAdapters()
-	pulseAmps = NewPulseAmps()
-	debugger = NewDebugger()
-	vm = NewVM(*vmPath)
-	defer vm.Close()
-	cycle = units.NewCycle(units.CycleConn{})
-	u = &units.ClockedUnits{}
-	u.Initiate = units.NewInitiate(units.InitiateConn{
-		Ppunch: ppunch,
-	})
-	u.Mp = units.NewMp()
-	u.Divsr = units.NewDivsr()
-	u.Multiplier = units.NewMultiplier()
-	u.Constant = units.NewConstant()
-	printer = units.NewPrinter()
-	for i := 0; i < 3; i++ {
-		u.Ft[i] = units.NewFt(i)
-	}
-	for i := 0; i < 20; i++ {
-		u.Accumulator[i] = units.NewAccumulator(i)
-	}
-	u.TenStepper = units.NewAuxStepper("st", 10)
-	u.FtSelector = units.NewAuxStepper("sft", 6)
-	u.OrderSelector = units.NewOrderSelector()
-	for i := 0; i < 2; i++ {
-		u.PmDiscriminator[i] = units.NewAuxStepper(fmt.Sprintf("pm%d", i+1), 2)
-	}
-	for i := 0; i < 2; i++ {
-		u.JkSelector[i] = units.NewAuxStepper(fmt.Sprintf("sjk%d", i+1), 6)
-	}
-
-	clearedUnits := []Cleared{u.Mp, u.Divsr}
-	for i := 0; i < 20; i++ {
-		clearedUnits = append(clearedUnits, u.Accumulator[i])
-	}
-
-	cycle.Io.Units = u
-	cycle.Io.SelectiveClear = func() bool { return u.Initiate.SelectiveClear() }
-	cycle.Io.StepAndVerifyVM = func() { vm.StepAndVerify() }
-	cycle.Io.StepAheadVM = func(cycle int64) { vm.StepAhead(cycle) }
-	u.Initiate.Io.Units = clearedUnits
-	u.Initiate.Io.AddCycle = func() int64 { return cycle.AddCycle }
-	u.Initiate.Io.Stepping = func() bool { return cycle.Stepping() }
-	u.Initiate.Io.ReadCard = func(s string) { u.Constant.ReadCard(s) }
-	u.Initiate.Io.Print = func() string { return printer.Print() }
-	u.Divsr.Io.Quotient = u.Accumulator[2-1]
-	u.Divsr.Io.Numerator = u.Accumulator[3-1]
-	u.Divsr.Io.Denominator = u.Accumulator[5-1]
-	u.Divsr.Io.Shift = u.Accumulator[7-1]
-	u.Multiplier.Io.Ier = u.Accumulator[9-1]
-	u.Multiplier.Io.Icand = u.Accumulator[10-1]
-	u.Multiplier.Io.Lhpp = u.Accumulator[11-1]
-	u.Multiplier.Io.Rhpp = u.Accumulator[13-1]
-	printer.Io.MpPrinterDecades = func() string { return u.Mp.PrinterDecades() }
-	for i := 0; i < 20; i++ {
-		printer.Io.Accumulator[i] = u.Accumulator[i]
-		debugger.Io.Accumulator[i] = u.Accumulator[i]
-	}
-
 	if flag.NArg() >= 1 {
-		doCommand(os.Stdout, "l "+flag.Arg(0))
+		machine.Exec(os.Stdout, "l "+flag.Arg(0))
 	}
 
 	if *testCycles > 0 {
-		doTraceStart(os.Stdout, []string{"ts", "pf"})
-		cycle.SetTestMode()
-		cycle.StepNAddCycles(*testCycles)
-		doDumpAll(os.Stdout)
-		doTraceEnd(os.Stdout, []string{"te", "/tmp/test.vcd"})
-		return
+		machine.Exec(os.Stdout, "ts pf")
+		machine.Cycle().SetTestMode()
+		machine.Run(*testCycles)
+		machine.DumpAll(os.Stdout)
+		machine.Exec(os.Stdout, "te /tmp/test.vcd")
+		exit()
 	}
 
 	sc := bufio.NewScanner(os.Stdin)
 	var prompt = func() {
 		if !*quiet {
-			fmt.Printf("%d> ", cycle.AddCycle)
+			fmt.Printf("%d> ", machine.AddCycle())
 		}
 	}
 	prompt()
 	for sc.Scan() {
-		if doCommand(os.Stdout, sc.Text()) < 0 {
+		if machine.Exec(os.Stdout, sc.Text()) < 0 {
 			break
 		}
 		prompt()
 	}
+	exit()
 }
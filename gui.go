@@ -128,11 +128,11 @@ func guicmd(sc *bufio.Scanner, gpipe io.Writer) {
 			drawfixed(gpipe)
 		} else if len(s) > 2 && s[:2] == "l " {
 			fmt.Fprintln(gpipe, "destroy .mlib")
-			doCommand(os.Stdout, s)
+			machine.Exec(os.Stdout, s)
 		} else if s == "update" {
 			guistate.upd <- 1
 		} else {
-			doCommand(os.Stdout, s)
+			machine.Exec(os.Stdout, s)
 		}
 	}
 }
@@ -622,7 +622,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 		}
 		needupdate = false
 		// Initiating unit
-		s := u.Initiate.Stat()
+		s := machine.Units().Initiate.Stat()
 		if s != guistate.lastinit {
 			for i, f := range s[:6] {
 				nname = fmt.Sprintf(".initc%d", i+1)
@@ -641,7 +641,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			needupdate = true
 		}
 		// Cycle unit
-		s = cycle.Stat()
+		s = machine.Cycle().Stat()
 		if s != guistate.lastcyc {
 			n, _ := strconv.Atoi(s)
 			neonplcl(gpipe, ".cycst", true, 122+642+(n/2)*20, 40)
@@ -650,8 +650,8 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			guistate.lastcyc = s
 			needupdate = true
 		}
-		if lastcmode != cycle.Mode() && !useControl {
-			lastcmode = cycle.Mode()
+		if lastcmode != machine.Cycle().Mode() && !useControl {
+			lastcmode = machine.Cycle().Mode()
 			switch lastcmode {
 			case units.OnePulse:
 				fmt.Fprintln(gpipe, ".cmode configure -text \"1 Pulse\"")
@@ -664,7 +664,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 		}
 		// Accumulators
 		for i := 1; i <= 20; i++ {
-			s = u.Accumulator[i-1].Stat()
+			s = machine.Units().Accumulator[i-1].Stat()
 			if s != guistate.lastacc[i-1] {
 				p := strings.Split(s, " ")
 				if p[0][0] == 'P' {
@@ -699,7 +699,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			}
 		}
 		// Divider/Square Rooter
-		s = u.Divsr.Stat()
+		s = machine.Units().Divsr.Stat()
 		if s != guistate.lastdiv {
 			p := strings.Split(s, " ")
 			plring, _ := strconv.Atoi(p[0])
@@ -748,7 +748,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			needupdate = true
 		}
 		// Multiplier
-		s = u.Multiplier.Stat()
+		s = machine.Units().Multiplier.Stat()
 		if s != guistate.lastmult {
 			p := strings.Split(s, " ")
 			stage, _ := strconv.Atoi(p[0])
@@ -769,7 +769,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			needupdate = true
 		}
 		// Master programmer
-		s = u.Mp.Stat()
+		s = machine.Units().Mp.Stat()
 		if s != guistate.lastmp {
 			for i := 0; i < 10; i++ {
 				d := int(s[i]) - int('0')
@@ -800,7 +800,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 		}
 		// Function tables
 		for i := 0; i < 3; i++ {
-			s = u.Ft[i].Stat()
+			s = machine.Units().Ft[i].Stat()
 			if s != guistate.lastft[i] {
 				p := strings.Split(s, " ")
 				for j, f := range p[0] {
@@ -826,7 +826,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			}
 		}
 		// Constant Transmitter
-		s = u.Constant.Stat()
+		s = machine.Units().Constant.Stat()
 		if s != guistate.lastcons {
 			for i, f := range s {
 				row := i / 10
@@ -846,7 +846,7 @@ func innergui(demoMode, tkKludge, useControl bool) {
 			needupdate = true
 		}
 	}
-	u.Initiate.Io.Ppunch <- "exit"
+	machine.Units().Initiate.Io.Ppunch <- "exit"
 	fmt.Fprintln(gpipe, "exit")
 	gpipe.Close()
 	cpipe.Close()
@@ -971,7 +971,7 @@ func prngui(gpipe io.Writer) {
 		fmt.Fprintf(gpipe, "place .outdeck -x %d -y %d\n", width-570, height-30)
 	}
 	for l := 0; ; l++ {
-		s := <-u.Initiate.Io.Ppunch
+		s := <-machine.Units().Initiate.Io.Ppunch
 		if s == "exit" {
 			return
 		}
@@ -1,4 +1,4 @@
-package main
+package eniac
 
 import (
 	"fmt"
@@ -14,6 +14,8 @@ type Adapters struct {
 	del     [80]deleter
 	sd      [80]specialDigit
 	permute [80]permuter
+
+	tracer Tracer
 }
 
 func NewAdapters() *Adapters {
@@ -59,22 +61,38 @@ func NewAdapters() *Adapters {
 	for i := 0; i < 80; i++ {
 		a.dp[i].in = NewInput(fmt.Sprintf("ad.dp.i.%d", i+1), dpInput(i))
 		for j := 0; j < 11; j++ {
-			a.dp[i].out[j] = NewOutput(fmt.Sprintf("ad.dp.o.%d.%d", i+1, j+1), nil)
+			a.dp[i].out[j] = a.newOutput(fmt.Sprintf("ad.dp.o.%d.%d", i+1, j+1), 1)
 		}
 		a.shift[i].in = NewInput(fmt.Sprintf("ad.s.i.%d", i+1), shiftInput(i))
-		a.shift[i].out = NewOutput(fmt.Sprintf("ad.s.o.%d", i+1), nil)
+		a.shift[i].out = a.newOutput(fmt.Sprintf("ad.s.o.%d", i+1), 11)
 		a.del[i].in = NewInput(fmt.Sprintf("ad.d.i.%d", i+1), delInput(i))
-		a.del[i].out = NewOutput(fmt.Sprintf("ad.d.o.%d", i+1), nil)
+		a.del[i].out = a.newOutput(fmt.Sprintf("ad.d.o.%d", i+1), 11)
 		a.sd[i].in = NewInput(fmt.Sprintf("ad.sd.i.%d", i+1), sdInput(i))
-		a.sd[i].out = NewOutput(fmt.Sprintf("ad.sd.o.%d", i+1), nil)
+		a.sd[i].out = a.newOutput(fmt.Sprintf("ad.sd.o.%d", i+1), 11)
 		a.permute[i].in = NewInput(fmt.Sprintf("ad.permute.i.%d", i+1), permuteInput(i))
-		a.permute[i].out = NewOutput(fmt.Sprintf("ad.permute.o.%d", i+1), nil)
+		a.permute[i].out = a.newOutput(fmt.Sprintf("ad.permute.o.%d", i+1), 11)
 		a.permute[i].in.OtherSide = a.permute[i].out
 		a.permute[i].out.OtherSide = a.permute[i].in
 	}
 	return a
 }
 
+func (a *Adapters) newOutput(name string, width int) *Jack {
+	jack := NewOutput(name, func(j *Jack, val int) {
+		if a.tracer != nil {
+			a.tracer.LogPulse(j.Name, width, int64(val))
+		}
+	})
+	jack.TraceBits = width
+	return jack
+}
+
+// AttachTracer connects a trace logger, which sees the values adapters send
+// on their outputs, e.g. ad.s.o.1 after shifting.
+func (a *Adapters) AttachTracer(tracer Tracer) {
+	a.tracer = tracer
+}
+
 type adParamSwitch struct {
 	minValue int
 	maxValue int
@@ -91,14 +109,14 @@ func (s *adParamSwitch) Set(value string) error {
 }
 
 func (s *adParamSwitch) Get() string {
-	return fmt.Sprintf("%d", s.data)
+	return fmt.Sprintf("%d", *s.data)
 }
 
 type permuteSwitch struct {
 	ad *permuter
 }
 
-//go:generate go run codegen/gen_permuters.go
+//go:generate go run ../../codegen/gen_permuters.go
 
 func (s *permuteSwitch) Set(value string) error {
 	order := strings.Split(value, ",")
@@ -137,7 +155,11 @@ func (s *permuteSwitch) Set(value string) error {
 }
 
 func (s *permuteSwitch) Get() string {
-	return fmt.Sprintf("%v", s.ad.order)
+	order := make([]string, 11)
+	for j := range order {
+		order[10-j] = strconv.Itoa(s.ad.order[j])
+	}
+	return strings.Join(order, ",")
 }
 
 func (a *Adapters) FindSwitch(name string) (Switch, error) {
@@ -219,6 +241,18 @@ type digitProgram struct {
 	refMask int // which digit outs have been referenced
 }
 
+// updateRefMasks recomputes which digit program adapter outputs are plugged.
+func (a *Adapters) updateRefMasks() {
+	for i := range a.dp {
+		a.dp[i].refMask = 0
+		for d, out := range a.dp[i].out {
+			if len(out.Receivers) != 0 {
+				a.dp[i].refMask |= 1 << uint(d)
+			}
+		}
+	}
+}
+
 // Emit program pulses when one or more digit positions activate.
 func (a *digitProgram) adapt(val int) {
 	//for i := uint(0); i < 11; i++ {
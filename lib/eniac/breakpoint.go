@@ -0,0 +1,195 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// breakpoint stops the machine when a pulse is sent on a jack or trunk.
+// Unlike debug.bp, breakpoints are not plugged into the rats nest; they tap
+// the outputs which drive the jack instead.
+type breakpoint struct {
+	id     int
+	target string // As given to the break command
+	jack   *Jack
+}
+
+// Break adds a breakpoint on target, which may be an output, an input or a
+// tray, and returns its number.
+func (m *Machine) Break(target string) (int, error) {
+	jack, _, err := m.findJack(target, 0)
+	if err != nil {
+		return 0, err
+	}
+	m.nextBreakpoint++
+	bp := &breakpoint{id: m.nextBreakpoint, target: target, jack: jack}
+	m.breakpoints = append(m.breakpoints, bp)
+	m.updateTaps()
+	return bp.id, nil
+}
+
+// DeleteBreakpoint removes breakpoint id.
+func (m *Machine) DeleteBreakpoint(id int) error {
+	for i, bp := range m.breakpoints {
+		if bp.id == id {
+			m.breakpoints = append(m.breakpoints[:i], m.breakpoints[i+1:]...)
+			m.updateTaps()
+			return nil
+		}
+	}
+	return fmt.Errorf("no breakpoint %d", id)
+}
+
+// updateTaps reinstalls debugging taps on jacks for breakpoints, text traces,
+// profiling, debug.contention and tracing nets.  Taps depend on cabling, so
+// this is called whenever it changes.  No taps are installed while replaying
+// add cycles for Back.
+func (m *Machine) updateTaps() {
+	for _, j := range m.tapped {
+		j.Tap = nil
+	}
+	m.tapped = nil
+	if m.replaying {
+		return
+	}
+	if m.debugger.contention.mode != contentionOff {
+		m.addContentionTaps()
+	}
+	if m.textLog != nil && m.textLog.causes {
+		m.addCauseTaps()
+	}
+	if m.textLog != nil && m.textLog.transfers {
+		m.addTransferTaps()
+	}
+	if m.profile != nil && m.profile.running {
+		m.addProfileTaps()
+	}
+	if m.coverage != nil && m.coverage.running {
+		m.addCoverageTaps()
+	}
+	for _, bp := range m.breakpoints {
+		m.addBreakpointTaps(bp)
+	}
+	if m.traceFilter != nil {
+		m.addTraceTriggerTaps()
+	}
+	m.addNetTraceTaps()
+}
+
+// addBreakpointTaps taps every output that sends pulses to bp's jack.  Units
+// may transmit words with no pulses in them, which don't hit.
+func (m *Machine) addBreakpointTaps(bp *breakpoint) {
+	m.tapPulsesTo(bp.jack, func(_ *Jack, val int) {
+		if val != 0 {
+			fmt.Fprintf(m.debugger.Io.Output, "[break %d] %s\n", bp.id, bp.target)
+			m.debugger.Io.Stop()
+		}
+	})
+}
+
+// tapPulsesTo calls hit whenever a pulse is sent on target, which may be an
+// output, an input or a tray.
+func (m *Machine) tapPulsesTo(target *Jack, hit JackHandler) {
+	if target.OnReceive == nil && !isRoutingJack(target) {
+		// An output.
+		m.addTap(target, hit)
+		return
+	}
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) {
+			continue
+		}
+		if isRoutingJack(target) {
+			if routesTo(j, target) {
+				m.addTap(j, hit)
+			}
+			continue
+		}
+		for _, r := range j.FinalReceivers() {
+			if r == target {
+				m.addTap(j, func(j *Jack, val int) {
+					if !target.Disabled {
+						hit(j, val)
+					}
+				})
+				break
+			}
+		}
+	}
+}
+
+// routesTo returns true if pulses sent by j pass through routing jack r.
+func routesTo(j, r *Jack) bool {
+	visited := make(map[*Jack]bool)
+	var visit func(*Jack) bool
+	visit = func(j *Jack) bool {
+		for _, x := range j.Receivers {
+			if x == r {
+				return true
+			}
+			if !visited[x] && isRoutingJack(x) {
+				visited[x] = true
+				if visit(x) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return visit(j)
+}
+
+// addTap adds tap to those called when j transmits.
+func (m *Machine) addTap(j *Jack, tap JackHandler) {
+	if prev := j.Tap; prev != nil {
+		j.Tap = func(j *Jack, val int) {
+			prev(j, val)
+			tap(j, val)
+		}
+	} else {
+		j.Tap = tap
+		m.tapped = append(m.tapped, j)
+	}
+}
+
+func (m *Machine) doBreak(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "break syntax: break jack")
+		return
+	}
+	if _, err := m.Break(f[1]); err != nil {
+		fmt.Fprintf(w, "break: %s\n", err)
+	}
+}
+
+func (m *Machine) doInfo(w io.Writer, f []string) {
+	if len(f) != 2 || f[1] != "breaks" {
+		fmt.Fprintln(w, "info syntax: info breaks")
+		return
+	}
+	for _, bp := range m.breakpoints {
+		fmt.Fprintf(w, "%d: break %s\n", bp.id, bp.target)
+	}
+}
+
+func (m *Machine) doDelete(w io.Writer, f []string) {
+	switch len(f) {
+	case 1:
+		m.breakpoints = nil
+		m.updateTaps()
+	case 2:
+		id, err := strconv.Atoi(f[1])
+		if err != nil {
+			fmt.Fprintf(w, "Invalid breakpoint %s\n", f[1])
+			return
+		}
+		if err := m.DeleteBreakpoint(id); err != nil {
+			fmt.Fprintf(w, "delete: %s\n", err)
+		}
+	default:
+		fmt.Fprintln(w, "delete syntax: delete [n]")
+	}
+}
@@ -0,0 +1,99 @@
+package eniac
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBreak(t *testing.T) {
+	tests := []struct {
+		target string
+		cycle  int64
+	}{
+		{"1-1", 2},
+		{"a2.α", 3},
+		{"1", 3},
+		{"a1.5o", 11},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		m := newCounter(&out)
+		plugs := m.plugLines()
+		m.Exec(&out, "break "+test.target)
+		if !reflect.DeepEqual(m.plugLines(), plugs) {
+			t.Errorf("%s: break changed plugs", test.target)
+		}
+		stopped := m.Run(20)
+		if got, want := out.String(), "[break 1] "+test.target+"\n"; got != want {
+			t.Errorf("%s: got %q; want %q", test.target, got, want)
+		}
+		if !stopped || m.AddCycle() != test.cycle {
+			t.Errorf("%s: Run stopped %v at %d; want %d", test.target, stopped, m.AddCycle(), test.cycle)
+		}
+	}
+}
+
+func TestBreakCommands(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	for _, command := range []string{
+		"break 3-7",
+		"break a5.5o",
+		"break f1.2i",
+		"break x.1",
+		"delete 2",
+		"delete 7",
+		"info breaks",
+	} {
+		m.Exec(&out, command)
+	}
+	want := "break: invalid unit name x\n" +
+		"delete: no breakpoint 7\n" +
+		"1: break 3-7\n" +
+		"3: break f1.2i\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	out.Reset()
+	m.Exec(&out, "break 1")
+	m.Exec(&out, "delete")
+	m.Exec(&out, "info breaks")
+	if stopped := m.Run(20); stopped || out.Len() != 0 {
+		t.Errorf("breakpoints remain after delete: %q", out.String())
+	}
+}
+
+func TestBreakIgnoresZeroTransmits(t *testing.T) {
+	// In the first stage of a multiplication, lhppII sends the left hand
+	// partial products shifted out of its 10 places, which is always 0.
+	for _, test := range []struct {
+		target string
+		cycle  int64
+	}{
+		{"m.lhppI", 5},
+		{"m.lhppII", 6},
+	} {
+		var out bytes.Buffer
+		m := newMachineWith(&out,
+			"set a9 9999999999",
+			"set a10 9999999999",
+			"p i.Io 1-1",
+			"p 1-1 m.1i",
+			"s m.ieracc1 α",
+			"s m.icandacc1 α",
+			"s m.prod1 A",
+			"s m.place1 10",
+			"b i",
+			"break "+test.target,
+		)
+		stopped := m.Run(20)
+		if !stopped || m.AddCycle() != test.cycle {
+			t.Errorf("%s: Run stopped %v at %d; want %d", test.target, stopped, m.AddCycle(), test.cycle)
+		}
+		if want := "[break 1] " + test.target + "\n"; !strings.HasPrefix(out.String(), want) {
+			t.Errorf("%s: got %q; want %q", test.target, out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package eniac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// capture works like a logic analyzer.  While armed, the wavedump only keeps
+// recent add cycles.  The first breakpoint, watchpoint or failed debug.assert
+// to stop the machine instead triggers the capture, and the machine runs on
+// for a few more add cycles before writing a vcd around the trigger and
+// stopping.
+type capture struct {
+	before   int // Add cycles to keep before the trigger
+	after    int // Add cycles to run after the trigger
+	filename string
+
+	triggered    bool
+	triggerCycle int64
+	triggerTime  int
+}
+
+// Capture arms a capture of pulses and/or regs which writes filename.
+func (m *Machine) Capture(pulses, regs bool, before, after int, filename string) {
+	m.closeStream(m.Io.Output)
+	m.startWavedump(pulses, regs)
+	m.waves.limit = before * pulseTimesPerAddCycle
+	m.capture = &capture{before: before, after: after, filename: filename}
+	m.updateAddCycleHook()
+}
+
+// stop handles requests from the debugger to stop the machine.
+func (m *Machine) stop() {
+	c := m.capture
+	if c == nil {
+		m.cycle.Stop()
+		return
+	}
+	if !c.triggered {
+		c.triggered = true
+		c.triggerCycle = m.cycle.AddCycle
+		c.triggerTime = m.waves.curTime
+		// Keep everything from here until the capture is written.
+		m.waves.limit = 0
+		fmt.Fprintf(m.debugger.Io.Output, "[capture] triggered at add cycle %d\n", c.triggerCycle)
+	}
+}
+
+// checkCapture is called at the end of each add cycle.
+func (m *Machine) checkCapture() {
+	c := m.capture
+	if !c.triggered || m.cycle.AddCycle-c.triggerCycle < int64(c.after) {
+		return
+	}
+	from := c.triggerTime - c.before*pulseTimesPerAddCycle
+	if from < m.waves.start {
+		from = m.waves.start
+	}
+	m.capture = nil
+	m.waves.limit = c.before * pulseTimesPerAddCycle
+	m.updateAddCycleHook()
+	defer m.cycle.Stop()
+	fd, err := os.Create(c.filename)
+	if err != nil {
+		fmt.Fprintf(m.debugger.Io.Output, "[capture] %s\n", err)
+		return
+	}
+	defer fd.Close()
+	bw := bufio.NewWriter(fd)
+	m.waves.window(from, m.waves.curTime).WriteVcd(bw, time.Now())
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintf(m.debugger.Io.Output, "[capture] %s\n", err)
+		return
+	}
+	fmt.Fprintf(m.debugger.Io.Output, "[capture] wrote %s\n", c.filename)
+}
+
+func (m *Machine) doCapture(w io.Writer, f []string) {
+	if len(f) == 2 && f[1] == "off" {
+		m.capture = nil
+		m.updateAddCycleHook()
+		return
+	}
+	if len(f) != 5 {
+		fmt.Fprintln(w, "capture syntax: capture p|f|pf before after file")
+		return
+	}
+	pulses := strings.IndexByte(f[1], 'p') != -1
+	regs := strings.IndexByte(f[1], 'f') != -1
+	if !pulses && !regs {
+		fmt.Fprintln(w, "capture: expecting p for pulses, f for regs")
+		return
+	}
+	before, err := strconv.Atoi(f[2])
+	if err != nil || before < 1 {
+		fmt.Fprintf(w, "capture: invalid add cycles before %s\n", f[2])
+		return
+	}
+	after, err := strconv.Atoi(f[3])
+	if err != nil || after < 0 {
+		fmt.Fprintf(w, "capture: invalid add cycles after %s\n", f[3])
+		return
+	}
+	m.Capture(pulses, regs, before, after, f[4])
+}
@@ -0,0 +1,73 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "capture.vcd")
+
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Exec(&out, "capture pf 2 1 "+filename)
+	m.Exec(&out, "watch a2 == 5")
+	if !m.Run(20) {
+		t.Errorf("Run did not stop")
+	}
+	want := "[watch 1] a2 == 5 (P 0000000005)\n" +
+		"[capture] triggered at add cycle 7\n" +
+		"[capture] wrote " + filename + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	// The machine runs on for one add cycle after the trigger.
+	if got := string(m.Units().Accumulator[1].Value()); got != "P 0000000006" {
+		t.Errorf("a2 = %s; want P 0000000006", got)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two add cycles before the trigger at the end of add cycle 7, and one
+	// after it.
+	var times []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			times = append(times, line)
+		}
+	}
+	if len(times) == 0 || times[0] != "#100" || times[len(times)-1] != "#160" {
+		t.Errorf("capture times = %v; want #100 to #160", times)
+	}
+}
+
+func TestCaptureSyntax(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	for _, command := range []string{
+		"capture pf 2 1",
+		"capture x 2 1 a.vcd",
+		"capture p 0 1 a.vcd",
+		"capture p 1 -1 a.vcd",
+		"capture off",
+	} {
+		m.Exec(&out, command)
+	}
+	want := "capture syntax: capture p|f|pf before after file\n" +
+		"capture: expecting p for pulses, f for regs\n" +
+		"capture: invalid add cycles before 0\n" +
+		"capture: invalid add cycles after -1\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package eniac
+
+import (
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// addCauseTaps taps every plugged output which sends program pulses, so that
+// ts c logs each pulse with the add cycle it was sent in, the output which
+// sent it, the trays it was routed through and the inputs which received it,
+// e.g.
+//
+//	12345 a5.5o -> B-3 -> {f1.2i, p.Ai}
+//
+// Following a program's control flow is then a matter of grepping for jacks.
+func (m *Machine) addCauseTaps() {
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) || len(j.Receivers) == 0 || !sendsProgramPulses(j) {
+			continue
+		}
+		route := programRoute(j)
+		m.addTap(j, func(j *Jack, val int) {
+			if val != 0 && m.textLog != nil {
+				m.textLog.printf("%d %s", m.cycle.AddCycle, route)
+			}
+		})
+	}
+}
+
+// sendsProgramPulses returns true if j is plugged into program trays or
+// directly into program inputs.
+func sendsProgramPulses(j *Jack) bool {
+	trunks := trunksFrom(j)
+	for _, t := range trunks {
+		if strings.Contains(t, "-") {
+			return true
+		}
+	}
+	if len(trunks) != 0 {
+		return false
+	}
+	for _, r := range j.FinalReceivers() {
+		if accDigitInput.MatchString(r.Name) || strings.HasPrefix(r.Name, "ad.") {
+			return false
+		}
+	}
+	return true
+}
+
+// programRoute describes how pulses from j reach their receivers.
+func programRoute(j *Jack) string {
+	var receivers []string
+	for _, r := range j.FinalReceivers() {
+		receivers = append(receivers, plugName(r))
+	}
+	receivers = uniqueStrings(receivers)
+	trunks := trunksFrom(j)
+	sort.Strings(trunks)
+	route := plugName(j) + " -> "
+	if len(trunks) != 0 {
+		route += strings.Join(trunks, ",") + " -> "
+	}
+	return route + "{" + strings.Join(receivers, ", ") + "}"
+}
@@ -0,0 +1,67 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCauseTrace(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Exec(&out, "p a2.5o 2-1")
+	m.Exec(&out, "p 2-1 a3.1i")
+	m.Exec(&out, "p 2-1 a4.1i")
+	m.Exec(&out, "ts c")
+	m.Cycle().SetTestMode()
+	m.Run(12)
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	// a1.A sends digits and is not logged.
+	var log bytes.Buffer
+	m.textLog.WriteLog(&log)
+	want := "1 i.Io -> 1-1 -> {a1.5i, a2.5i}\n" +
+		"10 a2.5o -> 2-1 -> {a3.1i, a4.1i}\n"
+	if got := log.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCauseTraceFile(t *testing.T) {
+	dir := t.TempDir()
+	want := "1 i.Io -> 1-1 -> {a1.5i, a2.5i}\n"
+	for _, test := range []struct {
+		start, end string
+		path       string
+	}{
+		{"ts c", "te " + filepath.Join(dir, "end.log"), "end.log"},
+		{"ts c " + filepath.Join(dir, "start.log"), "te", "start.log"},
+		{"ts c " + filepath.Join(dir, "exit.log"), "", "exit.log"},
+	} {
+		var out bytes.Buffer
+		m := newCounter(&out)
+		m.Exec(&out, test.start)
+		m.Cycle().SetTestMode()
+		m.Run(5)
+		if len(m.textLog.lines) != 0 {
+			t.Errorf("%s: lines kept in memory", test.start)
+		}
+		if test.end != "" {
+			m.Exec(&out, test.end)
+		} else {
+			m.CloseTraces(&out)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: unexpected output %q", test.start, out.String())
+		}
+		log, err := ioutil.ReadFile(filepath.Join(dir, test.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(log); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.start, got, want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// Check looks for likely mistakes in the machine's setup without running it,
+// writing one line to w per problem found.  Returns the number of problems.
+func (m *Machine) Check(w io.Writer) int {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	jacks := m.ratsNest.Jacks()
+
+	// Outputs plugged into trunks that no terminal listens to.
+	for _, j := range jacks {
+		if isRoutingJack(j) || len(j.Receivers) == 0 {
+			continue
+		}
+		if len(j.FinalReceivers()) == 0 {
+			report("%s is wired to nothing", plugName(j))
+		}
+	}
+
+	// Transceiver program inputs whose switches select no operation.  A
+	// transceiver with its output wired is a dummy program, though.
+	for _, j := range jacks {
+		if problem := m.idleProgram(j.Name); problem != "" {
+			report("%s is wired but %s", j.Name, problem)
+		}
+	}
+
+	// Repeat switches only exist for programs 5-12, so settings for 1-4 are
+	// rejected when loaded.
+	accRepeat := regexp.MustCompile(`^a\d+\.rp[1-4]$`)
+	for _, name := range m.unknownSwitches {
+		if accRepeat.MatchString(name) {
+			report("%s: accumulator programs 1-4 have no repeat switch", name)
+		}
+	}
+
+	m.checkTrunkConflicts(report)
+
+	// Adapters whose outputs are plugged but whose inputs are not.
+	for _, j := range jacks {
+		p := strings.Split(j.Name, ".")
+		if len(p) < 4 || p[0] != "ad" || p[2] != "o" {
+			continue
+		}
+		in, err := m.adapters.FindJack(fmt.Sprintf("i.%s.%s", p[1], p[3]))
+		if err == nil && !m.isDriven(in) {
+			report("ad.%s.%s has no input", p[1], p[3])
+		}
+	}
+
+	// Debugger triggers that are plugged but not configured.
+	for _, j := range jacks {
+		if strings.HasPrefix(j.Name, "debug.assert.") || strings.HasPrefix(j.Name, "debug.dump.") {
+			sw, err := m.debugger.FindSwitch(strings.TrimPrefix(j.Name, "debug."))
+			if err == nil && sw.Get() == "" {
+				report("%s is wired but not set", j.Name)
+			}
+		}
+	}
+
+	problems = uniqueStrings(problems)
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	return len(problems)
+}
+
+var (
+	accInput      = regexp.MustCompile(`^a(\d+)\.(\d+)i$`)
+	ftInput       = regexp.MustCompile(`^f(\d)\.(\d+)i$`)
+	constantInput = regexp.MustCompile(`^c\.(\d+)i$`)
+	multInput     = regexp.MustCompile(`^m\.(\d+)i$`)
+	divsrInput    = regexp.MustCompile(`^d\.(\d+)i$`)
+)
+
+// idleProgram returns why the program with input jack name does nothing, or
+// "" if it does something or isn't a program input.
+func (m *Machine) idleProgram(name string) string {
+	// switchesAre returns true if each switch is set as given.
+	switchesAre := func(settings ...string) bool {
+		for i := 0; i < len(settings); i += 2 {
+			sw, err := m.findSwitch(settings[i])
+			if err != nil || sw.Get() != settings[i+1] {
+				return false
+			}
+		}
+		return true
+	}
+	plugged := func(jack string) bool {
+		j, _, err := m.findJack(jack, 0)
+		return err == nil && len(j.Receivers) != 0
+	}
+	if p := accInput.FindStringSubmatch(name); p != nil {
+		op := fmt.Sprintf("a%s.op%s", p[1], p[2])
+		if switchesAre(op, "0") && !plugged(fmt.Sprintf("a%s.%so", p[1], p[2])) {
+			return op + " is 0"
+		}
+	}
+	if p := ftInput.FindStringSubmatch(name); p != nil {
+		// Every op switch setting looks up the argument, so only what is
+		// plugged matters, including NC or C if cl selects one.
+		unit := "f" + p[1]
+		cl := fmt.Sprintf("%s.cl%s", unit, p[2])
+		if !plugged(unit+".A") && !plugged(unit+".B") && !plugged(fmt.Sprintf("%s.%so", unit, p[2])) &&
+			!(switchesAre(cl, "NC") && plugged(unit+".NC")) && !(switchesAre(cl, "C") && plugged(unit+".C")) {
+			return fmt.Sprintf("%s.A and %s.B are not", unit, unit)
+		}
+	}
+	if p := constantInput.FindStringSubmatch(name); p != nil {
+		if !plugged("c.o") && !plugged(fmt.Sprintf("c.%so", p[1])) {
+			return "c.o is not"
+		}
+	}
+	if p := multInput.FindStringSubmatch(name); p != nil {
+		ier, icand, prod := "m.ieracc"+p[1], "m.icandacc"+p[1], "m.prod"+p[1]
+		if switchesAre(ier, "0", icand, "0", prod, "0") && !plugged(fmt.Sprintf("m.%so", p[1])) {
+			return fmt.Sprintf("%s, %s and %s are 0", ier, icand, prod)
+		}
+	}
+	if p := divsrInput.FindStringSubmatch(name); p != nil {
+		nr, dr, an := "d.nr"+p[1], "d.dr"+p[1], "d.an"+p[1]
+		if switchesAre(nr, "0", dr, "0", an, "OFF") && !plugged(fmt.Sprintf("d.%so", p[1])) {
+			return fmt.Sprintf("%s and %s are 0 and %s is OFF", nr, dr, an)
+		}
+	}
+	return ""
+}
+
+// checkTrunkConflicts reports digit trunks where two sources transmit in
+// response to the same program pulse.  Accumulators and the constant
+// transmitter send in the add cycle after their program pulse, function
+// tables some add cycles later and the multiplier's partial products later
+// still, so only sources of the same kind can clash.  The divider's answer
+// is sent after a delay which depends on its operands, and is not
+// considered.
+func (m *Machine) checkTrunkConflicts(report func(string, ...interface{})) {
+	// Map each terminal to the sources which drive it.
+	drivers := make(map[*Jack][]*Jack)
+	for _, j := range m.ratsNest.Jacks() {
+		for _, r := range j.FinalReceivers() {
+			drivers[r] = append(drivers[r], j)
+		}
+	}
+
+	const (
+		nextAddCycle = iota
+		functionTable
+		multiplier
+	)
+	type source struct {
+		name     string
+		timing   int
+		triggers map[string]bool
+	}
+	trunkSources := make(map[string][]source)
+	// addSource records that out transmits when any of inputs is pulsed.
+	addSource := func(name string, timing int, out *Jack, inputs []*Jack) {
+		if len(out.Receivers) == 0 {
+			return
+		}
+		triggers := make(map[string]bool)
+		for _, in := range inputs {
+			for _, d := range drivers[in] {
+				triggers[d.Name] = true
+			}
+		}
+		for _, trunk := range digitTrunks(out) {
+			trunkSources[trunk] = append(trunkSources[trunk], source{name, timing, triggers})
+		}
+	}
+	for i, a := range m.u.Accumulator {
+		for _, out := range []string{"A", "S"} {
+			jack, _ := a.FindJack(out)
+			var inputs []*Jack
+			for prog := 1; prog <= 12; prog++ {
+				sw, _ := a.FindSwitch(fmt.Sprintf("op%d", prog))
+				if op := sw.Get(); op == out || op == "AS" {
+					in, _ := a.FindJack(fmt.Sprintf("%di", prog))
+					inputs = append(inputs, in)
+				}
+			}
+			addSource(fmt.Sprintf("a%d.%s", i+1, out), nextAddCycle, jack, inputs)
+		}
+	}
+	var constantInputs []*Jack
+	for prog := 1; prog <= 30; prog++ {
+		in, _ := m.u.Constant.FindJack(fmt.Sprintf("%di", prog))
+		constantInputs = append(constantInputs, in)
+	}
+	out, _ := m.u.Constant.FindJack("o")
+	addSource("c.o", nextAddCycle, out, constantInputs)
+	for i, f := range m.u.Ft {
+		var inputs []*Jack
+		for prog := 1; prog <= 11; prog++ {
+			in, _ := f.FindJack(fmt.Sprintf("%di", prog))
+			inputs = append(inputs, in)
+		}
+		for _, out := range []string{"A", "B"} {
+			jack, _ := f.FindJack(out)
+			addSource(fmt.Sprintf("f%d.%s", i+1, out), functionTable, jack, inputs)
+		}
+	}
+	var multInputs []*Jack
+	for prog := 1; prog <= 24; prog++ {
+		in, _ := m.u.Multiplier.FindJack(fmt.Sprintf("%di", prog))
+		multInputs = append(multInputs, in)
+	}
+	for _, out := range []string{"lhppI", "lhppII", "rhppI", "rhppII"} {
+		jack, _ := m.u.Multiplier.FindJack(out)
+		addSource("m."+out, multiplier, jack, multInputs)
+	}
+
+	for trunk, sources := range trunkSources {
+		for i := range sources {
+			for j := i + 1; j < len(sources); j++ {
+				if sources[i].timing != sources[j].timing {
+					continue
+				}
+				var common []string
+				for t := range sources[i].triggers {
+					if sources[j].triggers[t] {
+						common = append(common, t)
+					}
+				}
+				if len(common) != 0 {
+					sort.Strings(common)
+					report("digit trunk %s: %s and %s both transmit on %s", trunk, sources[i].name, sources[j].name, strings.Join(common, ", "))
+				}
+			}
+		}
+	}
+}
+
+// digitTrunks returns the names of the data trunks reachable from j.
+func digitTrunks(j *Jack) []string {
+	var trunks []string
+	for _, t := range trunksFrom(j) {
+		if !strings.ContainsAny(t, "-.") {
+			trunks = append(trunks, t)
+		}
+	}
+	return trunks
+}
+
+// trunksFrom returns the names of the data and program trunks reachable from
+// j.
+func trunksFrom(j *Jack) []string {
+	var trunks []string
+	visited := make(map[*Jack]bool)
+	var visit func(*Jack)
+	visit = func(j *Jack) {
+		for _, r := range j.Receivers {
+			if visited[r] || !isRoutingJack(r) {
+				continue
+			}
+			visited[r] = true
+			if isTrayName(r.Name) {
+				trunks = append(trunks, r.Name)
+			}
+			visit(r)
+		}
+	}
+	visit(j)
+	return trunks
+}
+
+// isDriven returns true if any source sends pulses to j.
+func (m *Machine) isDriven(j *Jack) bool {
+	for _, s := range m.ratsNest.Jacks() {
+		for _, r := range s.FinalReceivers() {
+			if r == j {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isRoutingJack returns true for tray and pulse amplifier jacks, which only
+// pass pulses along.
+func isRoutingJack(j *Jack) bool {
+	return isTrayName(j.Name) || strings.HasPrefix(j.Name, "pa.")
+}
+
+func uniqueStrings(s []string) []string {
+	sort.Strings(s)
+	n := 0
+	for i := range s {
+		if i == 0 || s[i] != s[i-1] {
+			s[n] = s[i]
+			n++
+		}
+	}
+	return s[:n]
+}
+
+func (m *Machine) doCheck(w io.Writer) {
+	if m.Check(w) == 0 {
+		fmt.Fprintln(w, "no problems found")
+	}
+}
@@ -0,0 +1,140 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	m := NewMachine(MachineConn{Output: ioutil.Discard})
+	for _, command := range []string{
+		// a1.A and a2.A both transmit on 1 when i.Io pulses.
+		"p i.Io 1-1",
+		"p 1-1 a1.1i",
+		"p 1-1 a2.1i",
+		"s a1.op1 A",
+		"s a2.op1 A",
+		"p a1.A 1",
+		"p a2.A 1",
+		"p 1 a3.α",
+		// a3.2i has no operation.
+		"p 1-2 a3.2i",
+		// Dummy program, which is fine.
+		"p 1-3 a3.5i",
+		"p a3.5o 1-4",
+		"p 1-4 a4.1i",
+		"s a4.op1 α",
+		// Nobody listens to 1-5.
+		"p a4.6o 1-5",
+		"s a4.rp3 2",
+		"p ad.s.1 2",
+		"p 2 a5.α",
+		"p 1-6 debug.dump.1",
+	} {
+		m.Exec(ioutil.Discard, command)
+	}
+	var out bytes.Buffer
+	n := m.Check(&out)
+	want := "a3.2i is wired but a3.op2 is 0\n" +
+		"a4.6o is wired to nothing\n" +
+		"a4.rp3: accumulator programs 1-4 have no repeat switch\n" +
+		"ad.s.1 has no input\n" +
+		"debug.dump.1 is wired but not set\n" +
+		"digit trunk 1: a1.A and a2.A both transmit on i.Io\n"
+	if got := out.String(); got != want {
+		t.Errorf("Check wrote\n%s\nwant\n%s", got, want)
+	}
+	if n != 6 {
+		t.Errorf("Check returned %d; want 6", n)
+	}
+}
+
+func TestCheckOtherUnits(t *testing.T) {
+	m := NewMachine(MachineConn{Output: ioutil.Discard})
+	for _, command := range []string{
+		// Programs which do nothing.
+		"p 1-1 f1.1i",
+		// f1.2i only clears the argument accumulator, which is fine.
+		"p 1-2 f1.2i",
+		"s f1.cl2 C",
+		"p f1.C 1-5",
+		"p 1-5 a9.1i",
+		"s a9.op1 α",
+		"p 1-3 m.1i",
+		"s m.ieracc1 0",
+		"s m.icandacc1 0",
+		"s m.prod1 0",
+		"p 1-4 d.1i",
+		"s d.nr1 0",
+		"s d.dr1 0",
+		"s d.an1 OFF",
+		// The constant transmitter and a5 send on 2 in the same add cycle.
+		"p i.Io 2-1",
+		"p 2-1 c.2i",
+		"s c.s2 Al",
+		"p c.o 2",
+		"p 2-1 a5.1i",
+		"s a5.op1 A",
+		"p a5.A 2",
+		"p 2 a7.α",
+		// f2 and f3 look up on the same pulse and send on 3, but f2.B
+		// sends long after a6.A.
+		"p 2-1 f2.1i",
+		"p 2-1 f3.1i",
+		"p f2.A 3",
+		"p f3.A 3",
+		"p f2.B 2",
+		"p 3 a6.α",
+		// Partial products both go to 4 during a multiplication.
+		"p 2-1 m.2i",
+		"s m.prod2 A",
+		"p m.lhppI 4",
+		"p m.rhppI 4",
+		"p 4 a8.α",
+	} {
+		m.Exec(ioutil.Discard, command)
+	}
+	var out bytes.Buffer
+	m.Check(&out)
+	want := "d.1i is wired but d.nr1 and d.dr1 are 0 and d.an1 is OFF\n" +
+		"digit trunk 2: a5.A and c.o both transmit on i.Io\n" +
+		"digit trunk 3: f2.A and f3.A both transmit on i.Io\n" +
+		"digit trunk 4: m.lhppI and m.rhppI both transmit on i.Io\n" +
+		"f1.1i is wired but f1.A and f1.B are not\n" +
+		"m.1i is wired but m.ieracc1, m.icandacc1 and m.prod1 are 0\n"
+	if got := out.String(); got != want {
+		t.Errorf("Check wrote\n%s\nwant\n%s", got, want)
+	}
+
+	m = NewMachine(MachineConn{Output: ioutil.Discard})
+	m.Exec(ioutil.Discard, "p 1-1 c.1i")
+	out.Reset()
+	m.Check(&out)
+	if got, want := out.String(), "c.1i is wired but c.o is not\n"; got != want {
+		t.Errorf("Check wrote %q, want %q", got, want)
+	}
+}
+
+func TestCheckForgetsUnknownSwitches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rp.e")
+	if err := ioutil.WriteFile(path, []byte("s a1.rp1 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMachine(MachineConn{Output: ioutil.Discard})
+	m.Load(path)
+	var out bytes.Buffer
+	if m.Check(&out); out.Len() == 0 {
+		t.Errorf("Check found nothing after loading a1.rp1")
+	}
+	if err := ioutil.WriteFile(path, []byte("s a1.rp5 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.Exec(ioutil.Discard, "R")
+	m.Load(path)
+	out.Reset()
+	if m.Check(&out); out.Len() != 0 {
+		t.Errorf("Check after reloading wrote %q", out.String())
+	}
+}
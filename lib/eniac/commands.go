@@ -0,0 +1,1005 @@
+package eniac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	. "github.com/jeredw/eniacsim/lib"
+	"github.com/jeredw/eniacsim/lib/units"
+)
+
+// Exec runs one simulator command, writing any output to w.  Returns -1 if
+// the command asks to quit.
+func (m *Machine) Exec(w io.Writer, command string) int {
+	f := strings.Fields(command)
+	for i, s := range f {
+		if s[0] == '#' {
+			f = f[:i]
+			break
+		}
+	}
+	if len(f) == 0 {
+		return 0
+	}
+	switch f[0] {
+	case "b":
+		m.doButton(w, f)
+	case "back":
+		m.doBack(w, f)
+	case "break":
+		m.doBreak(w, f)
+	case "capture":
+		m.doCapture(w, f)
+	case "check":
+		m.doCheck(w)
+	case "coverage":
+		m.doCoverage(w, f)
+	case "d":
+		m.doDump(w, f)
+	case "D":
+		m.doDumpAll(w)
+	case "delete":
+		m.doDelete(w, f)
+	case "f":
+		m.doFile(w, f)
+	case "fault":
+		m.doFault(w, f)
+	case "g":
+		m.doRun(w, f)
+	case "info":
+		m.doInfo(w, f)
+	case "l":
+		m.doLoad(w, f)
+	case "n":
+		m.cycle.Step()
+		m.doDumpAll(w)
+	case "perf":
+		rate := float64(m.perfCycles) / m.perfTime.Seconds()
+		speedup := rate / 5000.0
+		fmt.Fprintf(w, "%.2f MHz (%d cycles, %v simulated, %v realtime [%.2fx])\n", rate/1e6, m.perfCycles, m.perfTime, time.Duration(speedup)*m.perfTime, speedup)
+	case "p":
+		m.doPlug(w, command, f)
+	case "p?":
+		m.doGetPlug(w, command, f)
+	case "profile":
+		m.doProfile(w, f)
+	case "q":
+		return -1
+	case "r":
+		m.doReset(w, f)
+	case "R":
+		m.doResetAll(w)
+	case "restore":
+		m.doRestore(w, f)
+	case "rn":
+		if err := m.Back(1); err != nil {
+			fmt.Fprintf(w, "rn: %s\n", err)
+		} else {
+			m.doDumpAll(w)
+		}
+	case "s":
+		m.doSetSwitch(w, command, f)
+	case "s?":
+		m.doGetSwitch(w, command, f)
+	case "save":
+		m.doSave(w, f)
+	case "set":
+		m.doSet(w, f)
+	case "ts":
+		m.doTraceStart(w, f)
+	case "te":
+		m.doTraceEnd(w, f)
+	case "unwatch":
+		m.doUnwatch(w, f)
+	case "w":
+		m.doWriteConfig(w, f)
+	case "watch":
+		m.doWatch(w, f)
+	case "dg":
+		m.doDumpGraph(w, f)
+	case "u":
+		m.doUnplug(w, command, f)
+	case "dt":
+	case "pt":
+	default:
+		fmt.Fprintf(w, "Unknown command: %s\n", command)
+	}
+	return 0
+}
+
+func (m *Machine) doButton(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "button syntax: b button")
+		return
+	}
+	switch f[1] {
+	case "c":
+		m.u.Initiate.PushClearButton()
+	case "i":
+		m.u.Initiate.PushInitButton()
+	case "p":
+		m.cycle.Step()
+	case "r":
+		m.u.Initiate.PushReadButton()
+	}
+}
+
+func (m *Machine) doDump(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "Status syntax: d unit")
+		return
+	}
+	switch f[1][0] {
+	case 'a':
+		unit, _ := strconv.Atoi(f[1][1:])
+		if !(unit >= 1 && unit <= 20) {
+			fmt.Fprintf(w, "Invalid accumulator %s\n", f[1][1:])
+			return
+		}
+		fmt.Fprintln(w, m.u.Accumulator[unit-1].Stat())
+	case 'c':
+		fmt.Fprintln(w, m.u.Constant.Stat())
+	case 'd':
+		fmt.Fprintln(w, m.u.Divsr.Stat2())
+	case 'f':
+		unit, _ := strconv.Atoi(f[1][1:])
+		if !(unit >= 1 && unit <= 3) {
+			fmt.Fprintf(w, "Invalid function table %s\n", f[1][1:])
+			return
+		}
+		fmt.Fprintln(w, m.u.Ft[unit-1].Stat())
+	case 'i':
+		fmt.Fprintln(w, m.u.Initiate.Stat())
+	case 'm':
+		fmt.Fprintln(w, m.u.Multiplier.Stat())
+	case 'p':
+		fmt.Fprintln(w, m.u.Mp.Stat())
+	}
+}
+
+func (m *Machine) doDumpAll(w io.Writer) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, m.u.Initiate.Stat())
+	fmt.Fprintln(w, m.u.Mp.Stat())
+	header := "      9876543210 9876543210 r 123456789012"
+	fmt.Fprintf(w, "%s   %s\n", header, header)
+	for i := 0; i < 20; i += 2 {
+		ai := m.u.Accumulator[i].Stat()
+		ai1 := m.u.Accumulator[i+1].Stat()
+		fmt.Fprintf(w, "a%-2d %s   a%-2d %s\n", i+1, ai, i+2, ai1)
+	}
+	fmt.Fprintln(w, m.u.Divsr.Stat2())
+	fmt.Fprintln(w, m.u.Multiplier.Stat())
+	for i := 0; i < 3; i++ {
+		fmt.Fprintln(w, m.u.Ft[i].Stat())
+	}
+	fmt.Fprintln(w, m.u.Constant.Stat())
+	fmt.Fprintln(w)
+}
+
+func (m *Machine) doFile(w io.Writer, f []string) {
+	if len(f) != 3 {
+		fmt.Fprintln(w, "file syntax: f (r|p) filename")
+		return
+	}
+	switch f[1] {
+	case "r":
+		fp, err := os.Open(f[2])
+		if err != nil {
+			fmt.Fprintf(w, "Card reader open: %s\n", err)
+			return
+		}
+		m.u.Initiate.SetCardScanner(bufio.NewScanner(fp))
+	case "p":
+		fp, err := os.Create(f[2])
+		if err != nil {
+			fmt.Fprintf(w, "Card punch open: %s\n", err)
+			return
+		}
+		m.u.Initiate.SetPunchWriter(bufio.NewWriter(fp))
+	}
+}
+
+func (m *Machine) doRun(w io.Writer, f []string) {
+	throttle := 0.0
+	if len(f) == 2 {
+		if strings.HasSuffix(f[1], "x") {
+			rate, _ := strconv.Atoi(f[1][:len(f[1])-1])
+			if !(rate >= 0 && rate <= 10000) {
+				fmt.Fprintf(w, "Invalid throttle setting %s (expect e.g. 20x)\n", f[1])
+				return
+			}
+			throttle = float64(rate)
+		} else {
+			fmt.Fprintf(w, "Invalid throttle setting %s (expect e.g. 20x)\n", f[1])
+			return
+		}
+	}
+	m.doSetSwitch(w, "s cy.op co", []string{"s", "cy.op", "co"})
+	interrupt := make(chan os.Signal, 1)
+	done := make(chan int)
+	signal.Notify(interrupt, os.Interrupt)
+	var elapsedTime time.Duration
+	var elapsedCycles int64
+	var stoppedByDebugger bool
+	go func() {
+		startTime := time.Now()
+		startCycle := m.cycle.AddCycle
+	loop:
+		for {
+			select {
+			case <-interrupt:
+				break loop
+			default:
+				if m.cycle.StepNAddCycles(10000) {
+					stoppedByDebugger = true
+					break loop
+				}
+			}
+			if throttle != 0.0 {
+				elapsedTime = time.Since(startTime)
+				elapsedCycles = m.cycle.AddCycle - startCycle
+				rate := float64(elapsedCycles) / elapsedTime.Seconds()
+				speedup := rate / 5000.0
+				if speedup > throttle {
+					extraTime := time.Duration(float64(elapsedTime) * (1 - throttle/speedup))
+					time.Sleep(extraTime)
+				}
+			}
+		}
+		elapsedTime = time.Since(startTime)
+		elapsedCycles = m.cycle.AddCycle - startCycle
+		done <- 1
+	}()
+	<-done
+	m.perfCycles += elapsedCycles
+	m.perfTime += elapsedTime
+	if stoppedByDebugger {
+		m.doDumpAll(w)
+	}
+}
+
+func (m *Machine) doLoad(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "Load syntax: l file")
+		return
+	}
+	if err := m.Load(f[1]); err != nil {
+		fmt.Fprintln(w, err)
+	}
+}
+
+func (m *Machine) doPlug(w io.Writer, command string, f []string) {
+	if len(f) != 3 {
+		fmt.Fprintln(w, "Invalid jumper spec", command)
+		return
+	}
+
+	p1 := strings.Split(f[1], ".")
+	p2 := strings.Split(f[2], ".")
+	if handled, err := m.doInterconnect(f[1], f[2], p1, p2); handled {
+		if err != nil {
+			fmt.Fprintf(w, "Interconnect: %s\n", err)
+		}
+		return
+	}
+
+	jack1, pb1, err := m.findJack(f[1], 0)
+	if err != nil {
+		fmt.Fprintf(w, "Plug error: %s\n", err)
+		return
+	}
+	err = m.setAdapterSwitchFromJack(pb1, p1)
+	if err != nil {
+		fmt.Fprintf(w, "Adapter: %s\n", err)
+		return
+	}
+	jack2, pb2, err := m.findJack(f[2], 1)
+	if err != nil {
+		fmt.Fprintf(w, "Plug error: %s\n", err)
+		fmt.Fprintln(w, command)
+		return
+	}
+	err = m.setAdapterSwitchFromJack(pb2, p2)
+	if err != nil {
+		fmt.Fprintf(w, "Adapter: %s\n", err)
+		return
+	}
+	err = Connect(m.ratsNest, jack1, jack2)
+	if err != nil {
+		fmt.Fprintf(w, "Plug error: %s\n", err)
+		return
+	}
+	m.rewired()
+}
+
+func (m *Machine) doUnplug(w io.Writer, command string, f []string) {
+	switch len(f) {
+	case 2:
+		// Adapters are named the same on both sides, so unplug both.
+		var jacks []*Jack
+		for pos := 0; pos < 2; pos++ {
+			jack, _, err := m.findJack(f[1], pos)
+			if err != nil {
+				fmt.Fprintf(w, "Unplug error: %s\n", err)
+				return
+			}
+			if pos == 0 || jack != jacks[0] {
+				jacks = append(jacks, jack)
+			}
+		}
+		for _, jack := range jacks {
+			m.unplugAll(jack)
+		}
+	case 3:
+		jack1, _, err := m.findJack(f[1], 0)
+		if err != nil {
+			fmt.Fprintf(w, "Unplug error: %s\n", err)
+			return
+		}
+		jack2, _, err := m.findJack(f[2], 1)
+		if err != nil {
+			fmt.Fprintf(w, "Unplug error: %s\n", err)
+			return
+		}
+		if err := Disconnect(m.ratsNest, jack1, jack2); err != nil {
+			fmt.Fprintf(w, "Unplug error: %s\n", err)
+		}
+	default:
+		fmt.Fprintln(w, "Invalid unplug spec", command)
+		return
+	}
+	m.rewired()
+}
+
+// unplugAll removes every cable plugged into j.  Pulse amplifier inputs stay
+// linked to their outputs since that connection is internal.
+func (m *Machine) unplugAll(j *Jack) {
+	for _, x := range m.ratsNest.Jacks() {
+		for _, r := range append([]*Jack(nil), x.Receivers...) {
+			if (x == j || r == j) && !isPulseAmpLink(x, r) {
+				Disconnect(m.ratsNest, x, r)
+			}
+		}
+	}
+}
+
+// rewired updates state which depends on cabling after a plug or unplug.
+func (m *Machine) rewired() {
+	m.adapters.updateRefMasks()
+	m.updateTaps()
+	if len(m.faults) != 0 {
+		m.updateFaults()
+	}
+}
+
+func (m *Machine) findJack(name string, pos int) (*Jack, Plugboard, error) {
+	p := strings.SplitN(name, ".", 2)
+	pb, err := m.findPlugboard(p[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	jackName := name
+	if pb != m.trays {
+		if len(p) != 2 {
+			return nil, nil, fmt.Errorf("bad jack name %s", name)
+		}
+		jackName = p[1]
+	}
+	if pb == m.adapters {
+		jackName = rewriteAdapterJackName(jackName, pos)
+	}
+	jack, err := pb.FindJack(jackName)
+	return jack, pb, err
+}
+
+func rewriteAdapterJackName(s string, pos int) string {
+	dir := "o."
+	if pos == 1 {
+		dir = "i."
+	}
+	return dir + s
+}
+
+func (m *Machine) setAdapterSwitchFromJack(pb Plugboard, p []string) error {
+	if pb == m.adapters && len(p) == 4 && p[1] != "dp" {
+		sw, err := m.adapters.FindSwitch(fmt.Sprintf("%s.%s", p[1], p[2]))
+		if err != nil {
+			return err
+		}
+		return sw.Set(p[3])
+	}
+	return nil
+}
+
+func (m *Machine) findPlugboard(name string) (Plugboard, error) {
+	switch {
+	case name == "ad":
+		return m.adapters, nil
+	case len(name) > 1 && name[0] == 'a':
+		n, _ := strconv.Atoi(name[1:])
+		if !(n >= 1 && n <= 20) {
+			return nil, fmt.Errorf("invalid accumulator %s", name[1:])
+		}
+		return m.u.Accumulator[n-1], nil
+	case name == "c":
+		return m.u.Constant, nil
+	case name == "cy":
+		return m.cycle, nil
+	case name == "d":
+		return m.u.Divsr, nil
+	case name == "debug":
+		return m.debugger, nil
+	case len(name) > 1 && name[0] == 'f':
+		n, _ := strconv.Atoi(name[1:])
+		if !(n >= 1 && n <= 3) {
+			return nil, fmt.Errorf("invalid function table %s", name[1:])
+		}
+		return m.u.Ft[n-1], nil
+	case name == "i":
+		return m.u.Initiate, nil
+	case name == "m":
+		return m.u.Multiplier, nil
+	case name == "os":
+		return m.u.OrderSelector, nil
+	case name == "p":
+		return m.u.Mp, nil
+	case name == "pa":
+		return m.pulseAmps, nil
+	case name == "sft":
+		return m.u.FtSelector, nil
+	case name == "sjk1":
+		return m.u.JkSelector[0], nil
+	case name == "sjk2":
+		return m.u.JkSelector[1], nil
+	case name == "st":
+		return m.u.TenStepper, nil
+	case name == "pm1":
+		return m.u.PmDiscriminator[0], nil
+	case name == "pm2":
+		return m.u.PmDiscriminator[1], nil
+	case isTrayName(name):
+		return m.trays, nil
+	}
+	return nil, fmt.Errorf("invalid unit name %s", name)
+}
+
+func isTrayName(name string) bool {
+	if len(name) >= 1 && unicode.IsDigit(rune(name[0])) {
+		return true
+	}
+	if len(name) >= 2 && (name[0] >= 'A' && name[0] <= 'Z') && name[1] == '-' {
+		return true
+	}
+	return false
+}
+
+func (m *Machine) doGetPlug(w io.Writer, command string, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "Invalid jumper spec", command)
+		return
+	}
+	jack, _, err := m.findJack(f[1], 0)
+	if err != nil {
+		fmt.Fprintf(w, "Get plug: %s\n", err)
+		return
+	}
+	fmt.Fprintf(w, "%s", jack.ConnectionsString())
+}
+
+func (m *Machine) doReset(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "Status syntax: r unit")
+		return
+	}
+	p := strings.Split(f[1], ".")
+	switch p[0] {
+	case "a":
+		if len(p) != 2 {
+			fmt.Fprintln(w, "Accumulator reset syntax: r a.unit")
+			return
+		}
+		unit, _ := strconv.Atoi(p[1])
+		if !(unit >= 1 && unit <= 20) {
+			fmt.Fprintf(w, "Invalid accumulator %s", p[1])
+			return
+		}
+		m.u.Accumulator[unit-1].Reset()
+	case "c":
+		m.u.Constant.Reset()
+	case "d":
+		m.u.Divsr.Reset()
+	case "f":
+		if len(p) != 2 {
+			fmt.Fprintln(w, "Function table reset syntax: r f.unit")
+			return
+		}
+		unit, _ := strconv.Atoi(p[1])
+		if !(unit >= 1 && unit <= 3) {
+			fmt.Fprintln(w, "Invalid function table")
+			return
+		}
+		m.u.Ft[unit-1].Reset()
+	case "i":
+		m.u.Initiate.Reset()
+	case "m":
+		m.u.Multiplier.Reset()
+	case "os":
+		m.u.OrderSelector.Reset()
+	case "p":
+		m.u.Mp.Reset()
+	case "pm1":
+		m.u.PmDiscriminator[0].Reset()
+	case "pm2":
+		m.u.PmDiscriminator[1].Reset()
+	case "st":
+		m.u.TenStepper.Reset()
+	case "sft":
+		m.u.FtSelector.Reset()
+	case "sjk1":
+		m.u.JkSelector[0].Reset()
+	case "sjk2":
+		m.u.JkSelector[1].Reset()
+	}
+}
+
+func (m *Machine) doResetAll(w io.Writer) {
+	m.u.Initiate.Reset()
+	m.u.Mp.Reset()
+	m.u.Ft[0].Reset()
+	m.u.Ft[1].Reset()
+	m.u.Ft[2].Reset()
+	for i := 0; i < 20; i++ {
+		m.u.Accumulator[i].Reset()
+	}
+	m.u.Divsr.Reset()
+	m.u.Multiplier.Reset()
+	m.u.Constant.Reset()
+	m.printer.Reset()
+	m.u.TenStepper.Reset()
+	m.u.OrderSelector.Reset()
+	m.resetHistory()
+	m.unknownSwitches = nil
+}
+
+func (m *Machine) findSwitch(name string) (Switch, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("missing switch name")
+	}
+	p := strings.SplitN(name, ".", 2)
+	if len(p) != 2 {
+		return nil, fmt.Errorf("invalid switch: %s", name)
+	}
+	sb, err := m.findSwitchboard(p[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid switch: %s", err)
+	}
+	sw, err := sb.FindSwitch(p[1])
+	if err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (m *Machine) findSwitchboard(name string) (Switchboard, error) {
+	switch {
+	case name == "ad":
+		return m.adapters, nil
+	case len(name) > 1 && name[0] == 'a':
+		n, _ := strconv.Atoi(name[1:])
+		if !(n >= 1 && n <= 20) {
+			return nil, fmt.Errorf("invalid accumulator %s", name[1:])
+		}
+		return m.u.Accumulator[n-1], nil
+	case name == "c":
+		return m.u.Constant, nil
+	case name == "cy":
+		return m.cycle, nil
+	case name == "d" || name == "ds":
+		return m.u.Divsr, nil
+	case name == "debug":
+		return m.debugger, nil
+	case len(name) > 1 && name[0] == 'f':
+		n, _ := strconv.Atoi(name[1:])
+		if !(n >= 1 && n <= 3) {
+			return nil, fmt.Errorf("invalid function table %s", name[1:])
+		}
+		return m.u.Ft[n-1], nil
+	case name == "m":
+		return m.u.Multiplier, nil
+	case name == "p":
+		return m.u.Mp, nil
+	case name == "pr":
+		return m.printer, nil
+	}
+	return nil, fmt.Errorf("invalid unit name %s", name)
+}
+
+func (m *Machine) doGetSwitch(w io.Writer, command string, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "expected s? u.switch")
+		return
+	}
+	sw, err := m.findSwitch(f[1])
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", sw.Get())
+}
+
+func (m *Machine) doSetSwitch(w io.Writer, command string, f []string) {
+	if len(f) < 3 {
+		fmt.Fprintln(w, "No switch setting")
+		return
+	}
+	sw, err := m.findSwitch(f[1])
+	if err != nil {
+		fmt.Fprintf(w, "error finding switch: %s\n", err)
+		m.unknownSwitches = append(m.unknownSwitches, f[1])
+		return
+	}
+	err = sw.Set(f[2])
+	if err != nil {
+		fmt.Fprintf(w, "error setting switch: %s\n", err)
+		return
+	}
+	if f[1] == "debug.contention" {
+		m.updateTaps()
+	}
+}
+
+func (m *Machine) doSet(w io.Writer, f []string) {
+	if len(f) != 3 {
+		fmt.Fprintln(w, "set syntax: set a13 -9876543210")
+		return
+	}
+	unit, _ := strconv.Atoi(f[1][1:])
+	if !(unit >= 1 && unit <= 20) {
+		fmt.Fprintf(w, "Invalid accumulator %s\n", f[1][1:])
+		return
+	}
+	value, err := strconv.ParseInt(f[2], 10, 64)
+	if err != nil {
+		fmt.Fprintf(w, "Invalid accumulator value %s\n", err)
+		return
+	}
+	m.u.Accumulator[unit-1].Set(value)
+	m.resetHistory()
+}
+
+func (m *Machine) doTraceStart(w io.Writer, f []string) {
+	if len(f) < 2 {
+		fmt.Fprintln(w, "trace start syntax: ts p|f|pf|c|r|t [file] [log] [glob...] [from=n] [to=n] [start=jack] [stop=jack]")
+		return
+	}
+	pulses := strings.IndexByte(f[1], 'p') != -1
+	regs := strings.IndexByte(f[1], 'f') != -1
+	causes := strings.IndexByte(f[1], 'c') != -1
+	transfers := strings.IndexByte(f[1], 'r') != -1
+	events := strings.IndexByte(f[1], 't') != -1
+	if !pulses && !regs && !causes && !transfers && !events {
+		fmt.Fprintln(w, "trace start: expecting p for pulses, f for regs, c for program causes, r for register transfers, t for timeline")
+		return
+	}
+	// Any argument naming a vcd file is where to stream the trace, one naming
+	// a log file is where to write the text log, and the rest select signals.
+	var filename, logFilename string
+	var filterArgs []string
+	for _, arg := range f[2:] {
+		if strings.HasSuffix(arg, ".vcd") || strings.HasSuffix(arg, ".vcd.gz") {
+			filename = arg
+		} else if strings.HasSuffix(arg, ".log") {
+			logFilename = arg
+		} else {
+			filterArgs = append(filterArgs, arg)
+		}
+	}
+	if filename != "" && !pulses && !regs {
+		fmt.Fprintln(w, "trace start: only p and f traces stream to a file")
+		return
+	}
+	if logFilename != "" && !causes && !transfers {
+		fmt.Fprintln(w, "trace start: only c and r traces write a log")
+		return
+	}
+	if filename != "" && logFilename == "" {
+		// The log goes alongside the vcd, as te would put it.
+		logFilename = filename + ".log"
+	}
+	if len(filterArgs) != 0 && !pulses && !regs {
+		fmt.Fprintln(w, "trace start: only p and f traces select signals")
+		return
+	}
+	var filter *traceFilter
+	if len(filterArgs) != 0 {
+		var err error
+		if filter, err = m.parseTraceFilter(filterArgs); err != nil {
+			fmt.Fprintf(w, "trace start: %s\n", err)
+			return
+		}
+	}
+	m.closeStream(w)
+	m.closeTextLog(w)
+	if causes || transfers {
+		textLog, err := newTraceLog(causes, transfers, logFilename)
+		if err != nil {
+			fmt.Fprintf(w, "trace start create: %s\n", err)
+			return
+		}
+		m.textLog = textLog
+		m.updateTaps()
+		m.updateAddCycleHook()
+	}
+	if events {
+		m.timeline = newTimeline()
+		m.updateAddCycleHook()
+	}
+	if !pulses && !regs {
+		return
+	}
+	var tracer Tracer
+	if filename != "" {
+		stream, err := newVcdStream(filename, pulses, regs)
+		if err != nil {
+			fmt.Fprintf(w, "trace start create: %s\n", err)
+			return
+		}
+		m.waves = nil
+		m.stream = stream
+		tracer = stream
+	} else {
+		m.waves = NewWavedump(pulses, regs)
+		tracer = m.waves
+	}
+	m.traceFilter = filter
+	if filter != nil {
+		filter.Tracer = tracer
+		tracer = filter
+	}
+	m.attachTracer(tracer)
+	if m.stream != nil {
+		m.declareStreamSignals()
+	}
+}
+
+// startWavedump starts recording a new wavedump.
+func (m *Machine) startWavedump(pulses, regs bool) {
+	if m.traceFilter != nil {
+		m.traceFilter = nil
+		m.updateTaps()
+	}
+	m.waves = NewWavedump(pulses, regs)
+	m.attachTracer(m.waves)
+}
+
+// attachTracer connects t to every unit which can be traced.
+func (m *Machine) attachTracer(t Tracer) {
+	m.tracer = &pausableTracer{Tracer: t}
+	t = m.tracer
+	for i := range m.u.Accumulator {
+		m.u.Accumulator[i].AttachTracer(t)
+	}
+	m.u.Multiplier.AttachTracer(t)
+	m.u.Constant.AttachTracer(t)
+	m.u.Divsr.AttachTracer(t)
+	m.u.Mp.AttachTracer(t)
+	for i := range m.u.Ft {
+		m.u.Ft[i].AttachTracer(t)
+	}
+	m.u.Initiate.AttachTracer(t)
+	m.u.TenStepper.AttachTracer(t)
+	m.u.FtSelector.AttachTracer(t)
+	m.u.OrderSelector.AttachTracer(t)
+	for i := range m.u.PmDiscriminator {
+		m.u.PmDiscriminator[i].AttachTracer(t)
+	}
+	for i := range m.u.JkSelector {
+		m.u.JkSelector[i].AttachTracer(t)
+	}
+	m.trays.AttachTracer(t)
+	m.adapters.AttachTracer(t)
+	m.pulseAmps.AttachTracer(t)
+	m.updateTaps()
+	m.cycle.AttachTracer(t)
+}
+
+// closeStream finishes writing any vcd being streamed.
+func (m *Machine) closeStream(w io.Writer) {
+	if m.stream == nil {
+		return
+	}
+	if err := m.stream.Close(); err != nil {
+		fmt.Fprintf(w, "trace end write: %s\n", err)
+	}
+	if n := m.stream.lateSignals(); n != 0 {
+		fmt.Fprintf(w, "trace end: left %d signals plugged after ts out of %s\n", n, m.stream.filename)
+	}
+	m.stream = nil
+}
+
+// closeTextLog finishes writing any text log, discarding it unless it was
+// written to a named file.
+func (m *Machine) closeTextLog(w io.Writer) {
+	if m.textLog == nil {
+		return
+	}
+	if err := m.textLog.Close(); err != nil {
+		fmt.Fprintf(w, "trace end write: %s\n", err)
+	}
+	m.textLog = nil
+	m.updateTaps()
+	m.updateAddCycleHook()
+}
+
+// CloseTraces finishes vcd streams and text logs being written to files named
+// by ts, for when the simulator exits before te.
+func (m *Machine) CloseTraces(w io.Writer) {
+	m.closeStream(w)
+	m.closeTextLog(w)
+}
+
+func (m *Machine) doTraceEnd(w io.Writer, f []string) {
+	logNamed := m.textLog != nil && m.textLog.filename != ""
+	if len(f) != 2 && !(len(f) == 1 && (m.stream != nil || logNamed)) {
+		fmt.Fprintln(w, "trace end syntax: te file")
+		return
+	}
+	if m.waves == nil && m.textLog == nil && m.stream == nil && m.timeline == nil {
+		fmt.Fprintln(w, "not tracing; missing ts?")
+		return
+	}
+	// The first trace goes in the named file and any others alongside it,
+	// e.g. in file.log.
+	var filename string
+	taken := false
+	if len(f) == 2 {
+		filename = f[1]
+	} else if m.stream != nil {
+		filename, taken = m.stream.filename, true
+	} else {
+		filename, taken = m.textLog.filename, true
+	}
+	next := func(suffix string) string {
+		if taken {
+			return filename + suffix
+		}
+		taken = true
+		return filename
+	}
+	if m.waves != nil {
+		fd, err := os.Create(next(".vcd"))
+		if err != nil {
+			fmt.Fprintf(w, "trace end create: %s\n", err)
+			return
+		}
+		bw := bufio.NewWriter(fd)
+		m.waves.WriteVcd(bw, time.Now())
+		bw.Flush()
+	}
+	m.closeStream(w)
+	if m.textLog != nil && m.textLog.filename != "" {
+		// Already written as it went, like a stream.
+		m.closeTextLog(w)
+	} else if m.textLog != nil {
+		fd, err := os.Create(next(".log"))
+		if err != nil {
+			fmt.Fprintf(w, "trace end create: %s\n", err)
+			return
+		}
+		defer fd.Close()
+		if err := m.textLog.WriteLog(fd); err != nil {
+			fmt.Fprintf(w, "trace end write: %s\n", err)
+		}
+	}
+	if m.timeline != nil {
+		fd, err := os.Create(next(".json"))
+		if err != nil {
+			fmt.Fprintf(w, "trace end create: %s\n", err)
+			return
+		}
+		defer fd.Close()
+		if err := m.timeline.WriteTimeline(fd, m.cycle.AddCycle); err != nil {
+			fmt.Fprintf(w, "trace end write: %s\n", err)
+		}
+	}
+}
+
+func (m *Machine) doDumpGraph(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "dump graph syntax: dg file")
+		return
+	}
+	fd, err := os.Create(f[1])
+	if err != nil {
+		fmt.Fprintf(w, "dump graph create: %s\n", err)
+		return
+	}
+	bw := bufio.NewWriter(fd)
+	m.ratsNest.DumpGraph(bw)
+	bw.Flush()
+}
+
+func (m *Machine) doInterconnect(f1 string, f2 string, p1 []string, p2 []string) (bool, error) {
+	// Handle commands like p aXX.{st,su,il,ir} *
+	if len(p1) == 2 && p1[0][0] == 'a' && len(p1[1]) >= 2 &&
+		len(p2) == 2 && p2[0][0] == 'a' && len(p2[1]) >= 2 &&
+		(p1[1][:2] == "il" || p1[1][:2] == "ir") &&
+		(p2[1][:2] == "il" || p2[1][:2] == "ir") {
+		return true, units.Interconnect(m.u.Accumulator, p1, p2)
+	}
+	if handled, err := m.doMultiplierInterconnect(f1, f2); handled {
+		return true, err
+	}
+	if handled, err := m.doDivsrInterconnect(f1, f2); handled {
+		return true, err
+	}
+	return false, nil
+}
+
+func (m *Machine) doMultiplierInterconnect(f1 string, f2 string) (bool, error) {
+	// Handle p m.[LR] aXX
+	if strings.HasPrefix(f2, "m.") {
+		f1, f2 = f2, f1
+	}
+	var conn *units.StaticWiring
+	switch f1 {
+	case "m.l", "m.L":
+		conn = &m.u.Multiplier.Io.Lhpp
+	case "m.r", "m.R":
+		conn = &m.u.Multiplier.Io.Rhpp
+	case "m.ier":
+		conn = &m.u.Multiplier.Io.Ier
+	case "m.icand":
+		conn = &m.u.Multiplier.Io.Icand
+	}
+	if conn != nil {
+		if len(f2) < 2 || !strings.HasPrefix(f2, "a") {
+			return true, fmt.Errorf("multiplier interconnect must be connected to accum")
+		}
+		unit, _ := strconv.Atoi(f2[1:])
+		if !(unit >= 1 && unit <= 20) {
+			return true, fmt.Errorf("invalid accumulator")
+		}
+		*conn = m.u.Accumulator[unit-1]
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *Machine) doDivsrInterconnect(f1 string, f2 string) (bool, error) {
+	// Handle p d.{} aXX
+	if strings.HasPrefix(f2, "d.") {
+		f1, f2 = f2, f1
+	}
+	var conn *units.StaticWiring
+	switch f1 {
+	case "d.quotient":
+		conn = &m.u.Divsr.Io.Quotient
+	case "d.numerator":
+		conn = &m.u.Divsr.Io.Numerator
+	case "d.denominator":
+		conn = &m.u.Divsr.Io.Denominator
+	case "d.shift":
+		conn = &m.u.Divsr.Io.Shift
+	}
+	if conn != nil {
+		if len(f2) < 2 || !strings.HasPrefix(f2, "a") {
+			return true, fmt.Errorf("divsr interconnect must be connected to accum")
+		}
+		unit, _ := strconv.Atoi(f2[1:])
+		if !(unit >= 1 && unit <= 20) {
+			return true, fmt.Errorf("invalid accumulator")
+		}
+		*conn = m.u.Accumulator[unit-1]
+		return true, nil
+	}
+	return false, nil
+}
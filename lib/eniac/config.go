@@ -0,0 +1,232 @@
+package eniac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+	"github.com/jeredw/eniacsim/lib/units"
+)
+
+// switchNames returns the name of every switch on m, as accepted by the s
+// command, including debugger switches allocated so far.
+func (m *Machine) switchNames() []string {
+	return append(unitSwitchNames(), m.debugger.switchNames()...)
+}
+
+// unitSwitchNames returns the name of every switch on the machine's units.
+// The cycling unit's operating mode (cy.op) is omitted since it changes as the
+// machine runs.
+func unitSwitchNames() []string {
+	var names []string
+	add := func(format string, lo, hi int) {
+		for i := lo; i <= hi; i++ {
+			names = append(names, fmt.Sprintf(format, i))
+		}
+	}
+	names = append(names, "cy.vm")
+	for a := 1; a <= 20; a++ {
+		prefix := fmt.Sprintf("a%d.", a)
+		add(prefix+"op%d", 1, 12)
+		add(prefix+"cc%d", 1, 12)
+		add(prefix+"rp%d", 5, 12)
+		names = append(names, prefix+"sf", prefix+"sc")
+	}
+	add("c.s%d", 1, 30)
+	names = append(names, "c.jl", "c.jr")
+	add("c.j%d", 1, 10)
+	names = append(names, "c.kl", "c.kr")
+	add("c.k%d", 1, 10)
+	names = append(names, "d.da", "d.ra")
+	for _, sw := range []string{"nr", "nc", "dr", "dc", "pl", "ro", "an", "il"} {
+		add("d."+sw+"%d", 1, 8)
+	}
+	for f := 1; f <= 3; f++ {
+		prefix := fmt.Sprintf("f%d.", f)
+		add(prefix+"op%d", 1, 11)
+		add(prefix+"cl%d", 1, 11)
+		add(prefix+"rp%d", 1, 11)
+		names = append(names, prefix+"mpm1", prefix+"mpm2")
+		for _, bank := range "AB" {
+			add(prefix+string(bank)+"%dD", 1, 4)
+			add(prefix+string(bank)+"%dC", 1, 4)
+			add(prefix+string(bank)+"%dS", 5, 10)
+		}
+		for _, bank := range "AB" {
+			for row := -2; row <= 101; row++ {
+				names = append(names, fmt.Sprintf("%sR%c%dS", prefix, bank, row))
+				add(fmt.Sprintf("%sR%c%dL", prefix, bank, row)+"%d", 1, 6)
+			}
+		}
+		names = append(names, prefix+"ninep")
+	}
+	names = append(names, "m.pp")
+	for _, sw := range []string{"ieracc", "iercl", "icandacc", "icandcl", "sf", "place", "prod"} {
+		add("m."+sw+"%d", 1, 24)
+	}
+	names = append(names, "p.gate63")
+	for _, a := range []int{20, 18, 14, 12, 10, 8, 4, 2} {
+		names = append(names, fmt.Sprintf("p.a%d", a))
+	}
+	for d := 1; d <= 20; d++ {
+		add(fmt.Sprintf("p.d%ds", d)+"%d", 1, 6)
+	}
+	for _, stepper := range "ABCDEFGHJK" {
+		names = append(names, "p.c"+string(stepper))
+	}
+	add("pr.%d", 1, 16)
+	for i := 1; i < 16; i++ {
+		names = append(names, fmt.Sprintf("pr.%d-%d", i, i+1))
+	}
+	add("pr.pm%d", 1, 80)
+	names = append(names, "debug.contention")
+	for _, kind := range []string{"s", "d", "sd", "permute"} {
+		add("ad."+kind+".%d", 1, 80)
+	}
+	return names
+}
+
+// WriteConfig writes commands which reproduce the machine's current cabling
+// and switch settings.  Only settings that differ from a newly constructed
+// machine are written, in a fixed order, so configurations can be compared
+// line by line.  Card decks and accumulator values are not included.
+func (m *Machine) WriteConfig(w io.Writer) error {
+	initial := NewMachine(MachineConn{Output: ioutil.Discard})
+	bw := bufio.NewWriter(w)
+	for _, line := range m.staticLines(initial) {
+		fmt.Fprintln(bw, line)
+	}
+	for _, line := range m.plugLines() {
+		fmt.Fprintln(bw, line)
+	}
+	for _, name := range m.switchNames() {
+		sw, err := m.findSwitch(name)
+		if err != nil {
+			return err
+		}
+		sw0, _ := initial.findSwitch(name)
+		if value := sw.Get(); value != sw0.Get() {
+			fmt.Fprintf(bw, "s %s %s\n", name, value)
+		}
+	}
+	return bw.Flush()
+}
+
+// staticLines returns p commands for accumulator interconnects and for
+// multiplier and divider connections which differ from initial.
+func (m *Machine) staticLines(initial *Machine) []string {
+	var lines []string
+	for i, a := range m.u.Accumulator {
+		if left, _ := a.Interconnected(); left != nil {
+			lines = append(lines, fmt.Sprintf("p a%d.il1 a%d.ir1", i+1, m.accumulatorNumber(left)))
+		}
+	}
+	statics := []struct {
+		name          string
+		conn, initial units.StaticWiring
+	}{
+		{"m.ier", m.u.Multiplier.Io.Ier, initial.u.Multiplier.Io.Ier},
+		{"m.icand", m.u.Multiplier.Io.Icand, initial.u.Multiplier.Io.Icand},
+		{"m.l", m.u.Multiplier.Io.Lhpp, initial.u.Multiplier.Io.Lhpp},
+		{"m.r", m.u.Multiplier.Io.Rhpp, initial.u.Multiplier.Io.Rhpp},
+		{"d.quotient", m.u.Divsr.Io.Quotient, initial.u.Divsr.Io.Quotient},
+		{"d.numerator", m.u.Divsr.Io.Numerator, initial.u.Divsr.Io.Numerator},
+		{"d.denominator", m.u.Divsr.Io.Denominator, initial.u.Divsr.Io.Denominator},
+		{"d.shift", m.u.Divsr.Io.Shift, initial.u.Divsr.Io.Shift},
+	}
+	for _, s := range statics {
+		n, n0 := m.accumulatorNumber(s.conn), initial.accumulatorNumber(s.initial)
+		if n != n0 && n != 0 {
+			lines = append(lines, fmt.Sprintf("p %s a%d", s.name, n))
+		}
+	}
+	return lines
+}
+
+// plugLines returns p commands for every connection in the rats nest.
+func (m *Machine) plugLines() []string {
+	var plugs []string
+	for _, jack := range m.ratsNest.Jacks() {
+		for _, r := range jack.Receivers {
+			if isPulseAmpLink(jack, r) {
+				continue
+			}
+			if jack.Name > r.Name && isReceiver(r, jack) {
+				// Two-way connection between trunks; write it only once.
+				continue
+			}
+			plugs = append(plugs, plugLine(jack, r))
+		}
+	}
+	sort.Strings(plugs)
+	return plugs
+}
+
+// plugLine returns the p command connecting j to r, written the same way
+// whichever end a two-way connection is seen from.
+func plugLine(j, r *Jack) string {
+	if j.Name > r.Name && isReceiver(r, j) {
+		j, r = r, j
+	}
+	return fmt.Sprintf("p %s %s", plugName(j), plugName(r))
+}
+
+// accumulatorNumber returns the 1-based number of accumulator a, or 0.
+func (m *Machine) accumulatorNumber(a units.StaticWiring) int {
+	for i := range m.u.Accumulator {
+		if a == units.StaticWiring(m.u.Accumulator[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func isReceiver(j, r *Jack) bool {
+	for _, x := range j.Receivers {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
+
+// isPulseAmpLink returns true if r is the output side of pulse amplifier
+// input j, which is hardwired rather than plugged.
+func isPulseAmpLink(j, r *Jack) bool {
+	return strings.HasPrefix(j.Name, "pa.") &&
+		r.Name == strings.Replace(j.Name, ".sa", ".sb", 1)
+}
+
+// plugName converts a jack name to the form used by the p command.  Adapter
+// jacks are named ad.kind.{i,o}.n internally, but the direction is implied by
+// argument order in p commands.
+func plugName(j *Jack) string {
+	if strings.HasPrefix(j.Name, "ad.") {
+		p := strings.Split(j.Name, ".")
+		if len(p) >= 4 && (p[2] == "i" || p[2] == "o") {
+			return strings.Join(append(p[:2:2], p[3:]...), ".")
+		}
+	}
+	return j.Name
+}
+
+func (m *Machine) doWriteConfig(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "write syntax: w file")
+		return
+	}
+	fd, err := os.Create(f[1])
+	if err != nil {
+		fmt.Fprintf(w, "write create: %s\n", err)
+		return
+	}
+	defer fd.Close()
+	if err := m.WriteConfig(fd); err != nil {
+		fmt.Fprintf(w, "write: %s\n", err)
+	}
+}
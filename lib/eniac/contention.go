@@ -0,0 +1,104 @@
+package eniac
+
+import (
+	"fmt"
+	"regexp"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// Settings for debug.contention.
+const (
+	contentionOff = iota
+	contentionWarn
+	contentionStop
+)
+
+func contentionSettings() []IntSwitchSetting {
+	return []IntSwitchSetting{
+		{"off", contentionOff},
+		{"warn", contentionWarn},
+		{"stop", contentionStop},
+	}
+}
+
+// contention tracks which source first drove each data trunk and accumulator
+// input during the current add cycle.  On the real machine, two sources
+// transmitting on the same trunk at once would add their pulses together.
+type contention struct {
+	mode     int
+	addCycle int64
+	trunks   map[string]*Jack
+	inputs   map[*Jack]*Jack
+	reported map[string]bool
+}
+
+// transmitted is called when source sends digit pulses reaching trunks and
+// accumulator digit inputs.
+func (u *Debugger) transmitted(source *Jack, trunks []string, inputs []*Jack) {
+	c := &u.contention
+	if cycle := u.Io.AddCycle(); c.trunks == nil || cycle != c.addCycle {
+		c.addCycle = cycle
+		c.trunks = make(map[string]*Jack)
+		c.inputs = make(map[*Jack]*Jack)
+		c.reported = make(map[string]bool)
+	}
+	for _, t := range trunks {
+		if other, ok := c.trunks[t]; !ok {
+			c.trunks[t] = source
+		} else if other != source {
+			u.reportContention(other, source, "on trunk "+t)
+		}
+	}
+	for _, in := range inputs {
+		if in.Disabled {
+			continue
+		}
+		if other, ok := c.inputs[in]; !ok {
+			c.inputs[in] = source
+		} else if other != source {
+			u.reportContention(other, source, "into "+in.Name)
+		}
+	}
+}
+
+func (u *Debugger) reportContention(first, second *Jack, where string) {
+	c := &u.contention
+	key := first.Name + " " + second.Name + " " + where
+	if c.reported[key] {
+		return
+	}
+	c.reported[key] = true
+	fmt.Fprintf(u.Io.Output, "[debug.contention] add cycle %d: %s and %s both transmit %s\n",
+		c.addCycle, plugName(first), plugName(second), where)
+	if c.mode == contentionStop {
+		u.Io.Stop()
+	}
+}
+
+var accDigitInput = regexp.MustCompile(`^a\d+\.[αβγδε]$`)
+
+// addContentionTaps installs a tap on each plugged output which reports its
+// digit pulses to the debugger.
+func (m *Machine) addContentionTaps() {
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) || len(j.Receivers) == 0 {
+			continue
+		}
+		trunks := digitTrunks(j)
+		var inputs []*Jack
+		for _, r := range j.FinalReceivers() {
+			if accDigitInput.MatchString(r.Name) {
+				inputs = append(inputs, r)
+			}
+		}
+		if len(trunks) == 0 && len(inputs) == 0 {
+			continue
+		}
+		m.addTap(j, func(j *Jack, val int) {
+			if val != 0 {
+				m.debugger.transmitted(j, trunks, inputs)
+			}
+		})
+	}
+}
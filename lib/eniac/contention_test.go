@@ -0,0 +1,43 @@
+package eniac
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContention(t *testing.T) {
+	for _, mode := range []string{"off", "warn", "stop"} {
+		var out bytes.Buffer
+		m := NewMachine(MachineConn{Output: &out})
+		for _, command := range []string{
+			"s debug.contention " + mode,
+			"set a1 5",
+			"set a2 7",
+			"p i.Io 1-1",
+			"p 1-1 a1.1i",
+			"p 1-1 a2.1i",
+			"p 1-1 a3.1i",
+			"s a1.op1 A",
+			"s a2.op1 A",
+			"s a3.op1 α",
+			"p a1.A 1",
+			"p a2.A 1",
+			"p 1 a3.α",
+			"b i",
+		} {
+			m.Exec(&out, command)
+		}
+		stopped := m.Run(10)
+		want := ""
+		if mode != "off" {
+			want = "[debug.contention] add cycle 2: a2.A and a1.A both transmit on trunk 1\n" +
+				"[debug.contention] add cycle 2: a2.A and a1.A both transmit into a3.α\n"
+		}
+		if got := out.String(); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", mode, got, want)
+		}
+		if stopped != (mode == "stop") {
+			t.Errorf("%s: Run returned %v", mode, stopped)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// coverage records which plugged connections carry pulses and which
+// transceiver programs and master programmer stages are triggered during a
+// run, to find wiring that tests never exercise.
+type coverage struct {
+	used    map[string]bool // Plug lines and program jacks that saw pulses
+	running bool
+}
+
+var (
+	// Transceiver program inputs: accumulator 1-12, function table 1-11,
+	// multiplier 1-24, divider 1-8 and constant 1-30.
+	programInput = regexp.MustCompile(`^(a\d+|f\d|m|d|c)\.\d+i$`)
+	// Master programmer stepper stage outputs.
+	stageOutput = regexp.MustCompile(`^p\.[A-K]\do$`)
+)
+
+// StartCoverage discards any previous coverage and starts recording.
+func (m *Machine) StartCoverage() {
+	m.coverage = &coverage{used: make(map[string]bool), running: true}
+	m.updateTaps()
+}
+
+// StopCoverage stops recording, keeping the coverage for reports.
+func (m *Machine) StopCoverage() {
+	if m.coverage != nil {
+		m.coverage.running = false
+		m.updateTaps()
+	}
+}
+
+// addCoverageTaps taps every plugged output to record the connections and
+// programs its pulses reach.
+func (m *Machine) addCoverageTaps() {
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) || len(j.Receivers) == 0 {
+			continue
+		}
+		reached := reachedFrom(j)
+		// Permute adapters with a single receiver may bypass their output
+		// entirely, so account for their traffic when it reaches their input.
+		type permuterReach struct {
+			p       *permuter
+			reached []string
+		}
+		var permuters []permuterReach
+		for _, r := range j.FinalReceivers() {
+			if p := m.hardwiredPermuter(r); p != nil {
+				permuters = append(permuters, permuterReach{p, reachedFrom(p.out)})
+			}
+		}
+		m.addTap(j, func(j *Jack, val int) {
+			if val == 0 || m.coverage == nil {
+				return
+			}
+			m.coverage.mark(reached)
+			for _, x := range permuters {
+				if permuted(x.p, val) != 0 {
+					m.coverage.mark(x.reached)
+				}
+			}
+		})
+	}
+}
+
+func (c *coverage) mark(names []string) {
+	for _, name := range names {
+		c.used[name] = true
+	}
+}
+
+// reachedFrom returns the plug lines pulses sent by j travel over, and the
+// programs they trigger.
+func reachedFrom(j *Jack) []string {
+	var names []string
+	if stageOutput.MatchString(j.Name) {
+		names = append(names, j.Name)
+	}
+	visited := make(map[*Jack]bool)
+	var visit func(*Jack)
+	visit = func(j *Jack) {
+		for _, r := range j.Receivers {
+			if !isPulseAmpLink(j, r) {
+				names = append(names, plugLine(j, r))
+			}
+			if visited[r] || !isRoutingJack(r) {
+				continue
+			}
+			visited[r] = true
+			visit(r)
+		}
+	}
+	visit(j)
+	for _, r := range j.FinalReceivers() {
+		if !r.Disabled && programInput.MatchString(r.Name) {
+			names = append(names, r.Name)
+		}
+	}
+	return uniqueStrings(names)
+}
+
+// coverageItem is a connection or program which a run may exercise.
+type coverageItem struct {
+	unit    string
+	name    string
+	program bool
+}
+
+// coverageItems returns every connection and every plugged program.
+func (m *Machine) coverageItems() []coverageItem {
+	var items []coverageItem
+	for _, j := range m.ratsNest.Jacks() {
+		if programInput.MatchString(j.Name) || stageOutput.MatchString(j.Name) {
+			items = append(items, coverageItem{unitName(j), j.Name, true})
+		}
+		for _, r := range j.Receivers {
+			if isPulseAmpLink(j, r) || (j.Name > r.Name && isReceiver(r, j)) {
+				continue
+			}
+			unit := "trays"
+			if !isRoutingJack(j) {
+				unit = unitName(j)
+			} else if !isRoutingJack(r) {
+				unit = unitName(r)
+			}
+			items = append(items, coverageItem{unit, plugLine(j, r), false})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].name < items[j].name
+	})
+	return items
+}
+
+// unitName returns the unit part of a jack name, e.g. a13 for a13.5i.
+func unitName(j *Jack) string {
+	if i := strings.IndexByte(j.Name, '.'); i != -1 {
+		return j.Name[:i]
+	}
+	return j.Name
+}
+
+// WriteCoverage writes a summary of which connections and programs c saw
+// used, per unit and overall, then lists those never exercised.
+func (m *Machine) WriteCoverage(w io.Writer, c *coverage) {
+	type counts struct{ conns, usedConns, progs, usedProgs int }
+	units := make(map[string]*counts)
+	var total counts
+	var unusedConns, unusedProgs []string
+	for _, item := range m.coverageItems() {
+		u, ok := units[item.unit]
+		if !ok {
+			u = &counts{}
+			units[item.unit] = u
+		}
+		used := c.used[item.name]
+		for _, n := range []*counts{u, &total} {
+			if item.program {
+				n.progs++
+				if used {
+					n.usedProgs++
+				}
+			} else {
+				n.conns++
+				if used {
+					n.usedConns++
+				}
+			}
+		}
+		switch {
+		case used:
+		case item.program:
+			unusedProgs = append(unusedProgs, item.name)
+		default:
+			unusedConns = append(unusedConns, item.name)
+		}
+	}
+	fraction := func(used, n int) string {
+		if n == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%d/%d (%.1f%%)", used, n, 100*float64(used)/float64(n))
+	}
+	fmt.Fprintf(w, "connections %s\n", fraction(total.usedConns, total.conns))
+	fmt.Fprintf(w, "programs %s\n", fraction(total.usedProgs, total.progs))
+	fmt.Fprintf(w, "overall %s\n", fraction(total.usedConns+total.usedProgs, total.conns+total.progs))
+	var names []string
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "by unit:")
+	for _, name := range names {
+		u := units[name]
+		fmt.Fprintf(w, "  %-6s connections %s programs %s\n", name, fraction(u.usedConns, u.conns), fraction(u.usedProgs, u.progs))
+	}
+	fmt.Fprintln(w, "unused connections:")
+	for _, name := range unusedConns {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+	fmt.Fprintln(w, "unused programs:")
+	for _, name := range unusedProgs {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+}
+
+func (m *Machine) doCoverage(w io.Writer, f []string) {
+	switch {
+	case len(f) == 2 && f[1] == "start":
+		m.StartCoverage()
+	case len(f) == 2 && f[1] == "stop":
+		m.StopCoverage()
+	case len(f) == 1:
+		if m.coverage == nil {
+			fmt.Fprintln(w, "no coverage; missing coverage start?")
+			return
+		}
+		m.WriteCoverage(w, m.coverage)
+	default:
+		fmt.Fprintln(w, "coverage syntax: coverage [start|stop]")
+	}
+}
@@ -0,0 +1,92 @@
+package eniac
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	for _, command := range []string{
+		"p 1-2 a3.5i",
+		"p a3.A 2",
+		"p p.A1o 1-3",
+		"p 1-3 a2.6i",
+		"coverage start",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Cycle().SetTestMode()
+	m.Run(12)
+	m.Exec(&out, "coverage")
+	want := "connections 5/9 (55.6%)\n" +
+		"programs 2/5 (40.0%)\n" +
+		"overall 7/14 (50.0%)\n" +
+		"by unit:\n" +
+		"  a1     connections 2/2 (100.0%) programs 1/1 (100.0%)\n" +
+		"  a2     connections 2/3 (66.7%) programs 1/2 (50.0%)\n" +
+		"  a3     connections 0/2 (0.0%) programs 0/1 (0.0%)\n" +
+		"  i      connections 1/1 (100.0%) programs -\n" +
+		"  p      connections 0/1 (0.0%) programs 0/1 (0.0%)\n" +
+		"unused connections:\n" +
+		"  p 1-2 a3.5i\n" +
+		"  p 1-3 a2.6i\n" +
+		"  p a3.A 2\n" +
+		"  p p.A1o 1-3\n" +
+		"unused programs:\n" +
+		"  a2.6i\n" +
+		"  a3.5i\n" +
+		"  p.A1o\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	out.Reset()
+	m.Exec(&out, "coverage stop")
+	m.Exec(&out, "coverage bogus")
+	if got, want := out.String(), "coverage syntax: coverage [start|stop]\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestCoverageOtherUnits(t *testing.T) {
+	var out bytes.Buffer
+	m := newMachineWith(&out,
+		"p i.Io 1-1",
+		"p 1-1 f1.1i",
+		"p 1-1 m.1i",
+		"p 1-1 d.1i",
+		"p 1-1 c.1i",
+		"p 1-1 p.Ai",
+		"p p.A1o 1-2",
+		"p p.A2o 1-3",
+		"p 1-2 f2.1i",
+		"p 1-3 m.2i",
+		"coverage start",
+		"b i",
+	)
+	m.Cycle().SetTestMode()
+	m.Run(3)
+	m.Exec(&out, "coverage")
+	want := "connections 8/10 (80.0%)\n" +
+		"programs 6/8 (75.0%)\n" +
+		"overall 14/18 (77.8%)\n" +
+		"by unit:\n" +
+		"  c      connections 1/1 (100.0%) programs 1/1 (100.0%)\n" +
+		"  d      connections 1/1 (100.0%) programs 1/1 (100.0%)\n" +
+		"  f1     connections 1/1 (100.0%) programs 1/1 (100.0%)\n" +
+		"  f2     connections 1/1 (100.0%) programs 1/1 (100.0%)\n" +
+		"  i      connections 1/1 (100.0%) programs -\n" +
+		"  m      connections 1/2 (50.0%) programs 1/2 (50.0%)\n" +
+		"  p      connections 2/3 (66.7%) programs 1/2 (50.0%)\n" +
+		"unused connections:\n" +
+		"  p 1-3 m.2i\n" +
+		"  p p.A2o 1-3\n" +
+		"unused programs:\n" +
+		"  m.2i\n" +
+		"  p.A2o\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,258 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// Debugger implements pseudo-units for debugging which may be plugged into the
+// machine like any other.  Assertions, breakpoints and dumps are allocated as
+// they are first named, e.g. debug.assert.fetchcheck, so there is no limit on
+// how many a configuration may use.
+type Debugger struct {
+	Io DebuggerConn
+
+	assert     map[string]*assertion
+	breakpoint map[string]*Jack
+	dump       map[string]*dump
+	quit       *Jack
+
+	contention contention
+}
+
+type DebuggerConn struct {
+	Probe func(name string) (func() string, error) // Reads unit state for assert and dump
+
+	Output   io.Writer    // Where to print debug messages
+	Stop     func()       // Stop the clock at the next add cycle boundary
+	Quit     func()       // Handle debug.quit
+	AddCycle func() int64 // Current add cycle, for reports
+}
+
+func NewDebugger(io DebuggerConn) *Debugger {
+	u := &Debugger{
+		Io:         io,
+		assert:     make(map[string]*assertion),
+		breakpoint: make(map[string]*Jack),
+		dump:       make(map[string]*dump),
+	}
+	u.quit = NewInput("debug.quit", func(*Jack, int) {
+		u.Io.Quit()
+	})
+	return u
+}
+
+var probeID = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func (u *Debugger) newBreakpoint(id string) *Jack {
+	return NewInput("debug.bp."+id, func(j *Jack, val int) {
+		fmt.Fprintf(u.Io.Output, "[debug.bp.%s] break", id)
+		u.Io.Stop()
+	})
+}
+
+func (u *Debugger) newDump(id string) *dump {
+	dump := &dump{probe: u.Io.Probe}
+	dump.trigger = NewInput("debug.dump."+id, func(j *Jack, val int) {
+		if dump.value != nil {
+			fmt.Fprintf(u.Io.Output, "[debug.dump.%s] %s = %s\n", id, dump.target, dump.value())
+		}
+	})
+	return dump
+}
+
+func (u *Debugger) newAssertion(id string) *assertion {
+	assert := &assertion{probe: u.Io.Probe}
+	assert.trigger = NewInput("debug.assert."+id, func(j *Jack, val int) {
+		if assert.value == nil {
+			return
+		}
+		assert.checks++
+		if value := assert.value(); !assert.test() {
+			assert.failures = append(assert.failures, assertFailure{u.Io.AddCycle(), value})
+			fmt.Fprintf(u.Io.Output, "[debug.assert.%s] %s = %s !~ %s\n", id, assert.target, value, assert.expectedDigits)
+			u.Io.Stop()
+		}
+	})
+	return assert
+}
+
+// splitName splits a name like assert.1 into its kind and id.
+func splitName(name string) (kind, id string, err error) {
+	p := strings.Split(name, ".")
+	if len(p) != 2 {
+		return "", "", fmt.Errorf("invalid debugger connection %s", name)
+	}
+	if !probeID.MatchString(p[1]) {
+		return "", "", fmt.Errorf("invalid id %s", p[1])
+	}
+	return p[0], p[1], nil
+}
+
+func (u *Debugger) assertion(id string) *assertion {
+	if u.assert[id] == nil {
+		u.assert[id] = u.newAssertion(id)
+	}
+	return u.assert[id]
+}
+
+func (u *Debugger) dumpNamed(id string) *dump {
+	if u.dump[id] == nil {
+		u.dump[id] = u.newDump(id)
+	}
+	return u.dump[id]
+}
+
+func (u *Debugger) FindJack(name string) (*Jack, error) {
+	if name == "quit" {
+		return u.quit, nil
+	}
+	kind, id, err := splitName(name)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "assert":
+		return u.assertion(id).trigger, nil
+	case "bp":
+		if u.breakpoint[id] == nil {
+			u.breakpoint[id] = u.newBreakpoint(id)
+		}
+		return u.breakpoint[id], nil
+	case "dump":
+		return u.dumpNamed(id).trigger, nil
+	}
+	return nil, fmt.Errorf("invalid debugger connection %s", name)
+}
+
+func (u *Debugger) FindSwitch(name string) (Switch, error) {
+	if name == "contention" {
+		return &IntSwitch{"debug.contention", &u.contention.mode, contentionSettings()}, nil
+	}
+	kind, id, err := splitName(name)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "assert":
+		return u.assertion(id), nil
+	case "dump":
+		return u.dumpNamed(id), nil
+	}
+	return nil, fmt.Errorf("invalid debugger switch %s", name)
+}
+
+// switchNames returns the names of assertion and dump switches which have been
+// allocated, sorted.
+func (u *Debugger) switchNames() []string {
+	var names []string
+	for id := range u.assert {
+		names = append(names, "debug.assert."+id)
+	}
+	for id := range u.dump {
+		names = append(names, "debug.dump."+id)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// assertion checks unit state when triggered and stops if it doesn't match:
+//   p 1-1 debug.assert.1
+//   s debug.assert.1 a5~Mxxxxxxxxxx
+//   #s debug.assert.1 a5~Pxxxxxxxxxx
+//   #s debug.assert.fetch p.A~3
+//   #s debug.assert.arg f1.arg~x4
+type assertion struct {
+	trigger        *Jack
+	probe          func(string) (func() string, error)
+	target         string
+	value          func() string
+	expectedDigits string // 'x' means don't care
+
+	checks   int // Times triggered
+	failures []assertFailure
+}
+
+type assertFailure struct {
+	cycle  int64
+	actual string
+}
+
+// test returns true if the probed value matches, ignoring the space between
+// sign and digits.
+func (s *assertion) test() bool {
+	value := strings.Replace(s.value(), " ", "", 1)
+	if len(value) != len(s.expectedDigits) {
+		return false
+	}
+	for i := range value {
+		if s.expectedDigits[i] != 'x' && value[i] != s.expectedDigits[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *assertion) Set(value string) error {
+	p := strings.Split(value, "~")
+	if len(p) != 2 {
+		return fmt.Errorf("invalid assertion %s", value)
+	}
+	target, digits := p[0], p[1]
+	probe, err := s.probe(target)
+	if err != nil {
+		return fmt.Errorf("%s in assertion", err)
+	}
+	if len(digits) != len(strings.Replace(probe(), " ", "", 1)) {
+		return fmt.Errorf("invalid digit string in assertion '%s'", digits)
+	}
+	for i := 0; i < len(digits); i++ {
+		switch {
+		case digits[i] == 'x':
+		case i == 0 && (digits[i] == 'M' || digits[i] == 'P'):
+		case digits[i] >= '0' && digits[i] <= '9':
+		default:
+			return fmt.Errorf("invalid digit string in assertion")
+		}
+	}
+	s.target = target
+	s.value = probe
+	s.expectedDigits = digits
+	return nil
+}
+
+func (s *assertion) Get() string {
+	if s.value == nil {
+		return ""
+	}
+	return s.target + "~" + s.expectedDigits
+}
+
+// dump prints unit state when triggered:
+//   p 1-1 debug.dump.1
+//   s debug.dump.1 a20
+type dump struct {
+	trigger *Jack
+	probe   func(string) (func() string, error)
+	target  string
+	value   func() string
+}
+
+func (s *dump) Set(value string) error {
+	probe, err := s.probe(value)
+	if err != nil {
+		return fmt.Errorf("%s in dump", err)
+	}
+	s.target = value
+	s.value = probe
+	return nil
+}
+
+func (s *dump) Get() string {
+	return s.target
+}
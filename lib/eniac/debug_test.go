@@ -0,0 +1,80 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	m := NewMachine(MachineConn{Output: ioutil.Discard})
+	m.Exec(ioutil.Discard, "set a3 42")
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"a3", "P 0000000042"},
+		{"p.A", "1"},
+		{"p.K", "1"},
+		{"p.d20", "0"},
+		{"f1.arg", "00"},
+		{"f2.A", "P 000000"},
+		{"f3.B", "P 000000"},
+		{"c", "P 0000000000"},
+		{"m.ier", "P 0000000000"},
+		{"m.icand", "P 0000000000"},
+		{"st", "1"},
+		{"sjk2", "1"},
+		{"a21", ""},
+		{"p.I", ""},
+		{"p.d21", ""},
+		{"f4.arg", ""},
+		{"f1.C", ""},
+		{"pm3", ""},
+	}
+	for _, test := range tests {
+		probe, err := m.probe(test.name)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("probe(%s) = %s; want error", test.name, probe())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("probe(%s): %s", test.name, err)
+		} else if got := probe(); got != test.want {
+			t.Errorf("probe(%s) = %s; want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNamedProbes(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	for _, command := range []string{
+		"p 1-1 debug.dump.start",
+		"s debug.dump.start p.A",
+		"p 1-1 debug.assert.fetchcheck",
+		"s debug.assert.fetchcheck a1~P0000000002",
+		"p 1-1 debug.assert.100",
+		"s debug.assert.100 p.A~1",
+		"s debug.assert.short a1~P1",
+		"s debug.dump.bad q7",
+		"p 1-1 debug.bp.bad-name",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Run(2)
+	want := "error setting switch: invalid digit string in assertion 'P1'\n" +
+		"error setting switch: invalid probe q7 in dump\n" +
+		"Plug error: invalid id bad-name\n" +
+		"p 1-1 debug.bp.bad-name\n" +
+		"[debug.dump.start] p.A = 1\n" +
+		"[debug.assert.fetchcheck] a1 = P 0000000001 !~ P0000000002\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if sw, _ := m.findSwitch("debug.assert.100"); sw.Get() != "p.A~1" {
+		t.Errorf("debug.assert.100 = %s; want p.A~1", sw.Get())
+	}
+}
@@ -0,0 +1,153 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// Diff writes the differences in setup between machines a and b to w, and
+// returns how many there are.  Connections are compared after routing through
+// trays and pulse amplifiers, so the choice of trunk lines doesn't matter, only
+// which terminals end up connected.  Plugs which connect no source to a
+// terminal are compared as plugged.  Neither machine is changed.
+func Diff(w io.Writer, a, b *Machine) int {
+	initial := NewMachine(MachineConn{Output: ioutil.Discard})
+	n := diffLines(w, a.staticLines(initial), b.staticLines(initial))
+	n += diffLines(w, a.connections(), b.connections())
+	n += diffLines(w, a.danglingPlugs(), b.danglingPlugs())
+	debugNames := append(a.debugger.switchNames(), b.debugger.switchNames()...)
+	names := append(unitSwitchNames(), uniqueStrings(debugNames)...)
+	for _, name := range names {
+		swa, err := a.diffSwitch(initial, name)
+		if err != nil {
+			continue
+		}
+		swb, _ := b.diffSwitch(initial, name)
+		if va, vb := swa.Get(), swb.Get(); va != vb {
+			fmt.Fprintf(w, "%s changed %s → %s\n", name, va, vb)
+			n++
+		}
+	}
+	return n
+}
+
+// connections returns a p command for each source terminal and each terminal
+// it ultimately drives, sorted.
+func (m *Machine) connections() []string {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, jack := range m.ratsNest.Jacks() {
+		for _, r := range jack.FinalReceivers() {
+			line := fmt.Sprintf("p %s %s", plugName(jack), plugName(r))
+			if !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// diffSwitch finds switch name like findSwitch, but debugger switches m
+// hasn't allocated are looked up on initial instead so as not to allocate
+// them on m.
+func (m *Machine) diffSwitch(initial *Machine, name string) (Switch, error) {
+	if strings.HasPrefix(name, "debug.") {
+		for _, allocated := range m.debugger.switchNames() {
+			if name == allocated {
+				return m.findSwitch(name)
+			}
+		}
+		return initial.findSwitch(name)
+	}
+	return m.findSwitch(name)
+}
+
+// danglingPlugs returns p commands for plugs which carry pulses from no source
+// to any terminal, such as an output plugged into a tray nothing reads,
+// sorted.  These don't show up in connections.
+func (m *Machine) danglingPlugs() []string {
+	jacks := m.ratsNest.Jacks()
+	// Trays reached from some source.
+	driven := make(map[*Jack]bool)
+	var drive func(*Jack)
+	drive = func(j *Jack) {
+		for _, r := range j.Receivers {
+			if isRoutingJack(r) && !driven[r] {
+				driven[r] = true
+				drive(r)
+			}
+		}
+	}
+	for _, j := range jacks {
+		if !isRoutingJack(j) {
+			drive(j)
+		}
+	}
+	reachesTerminal := func(j *Jack) bool {
+		visited := map[*Jack]bool{j: true}
+		var visit func(*Jack) bool
+		visit = func(j *Jack) bool {
+			for _, r := range j.Receivers {
+				if !isRoutingJack(r) {
+					return true
+				}
+				if !visited[r] {
+					visited[r] = true
+					if visit(r) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		return visit(j)
+	}
+	live := make(map[string]bool)
+	for _, j := range jacks {
+		for _, r := range j.Receivers {
+			if (!isRoutingJack(j) || driven[j]) && (!isRoutingJack(r) || reachesTerminal(r)) {
+				live[plugLine(j, r)] = true
+			}
+		}
+	}
+	var lines []string
+	for _, line := range m.plugLines() {
+		if !live[line] {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// diffLines reports lines only in a as removed and lines only in b as added.
+func diffLines(w io.Writer, a, b []string) int {
+	inA := make(map[string]bool)
+	for _, line := range a {
+		inA[line] = true
+	}
+	inB := make(map[string]bool)
+	for _, line := range b {
+		inB[line] = true
+	}
+	n := 0
+	for _, line := range a {
+		if !inB[line] {
+			fmt.Fprintf(w, "%s removed\n", line)
+			n++
+		}
+	}
+	for _, line := range b {
+		if !inA[line] {
+			fmt.Fprintf(w, "%s added\n", line)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := NewMachine(MachineConn{Output: ioutil.Discard})
+	b := NewMachine(MachineConn{Output: ioutil.Discard})
+	a.Exec(ioutil.Discard, "p a1.A 1")
+	a.Exec(ioutil.Discard, "s a1.op1 A")
+	a.Exec(ioutil.Discard, "p 1 a2.α")
+	// Same terminals connected through a different trunk, plus one more.
+	b.Exec(ioutil.Discard, "p 2 a2.α")
+	b.Exec(ioutil.Discard, "p 2 a3.β")
+	b.Exec(ioutil.Discard, "p a1.A 2")
+	b.Exec(ioutil.Discard, "s a1.op1 S")
+	b.Exec(ioutil.Discard, "p m.ier a4")
+
+	var out bytes.Buffer
+	n := Diff(&out, a, b)
+	want := "p m.ier a4 added\n" +
+		"p a1.A a3.β added\n" +
+		"a1.op1 changed A → S\n"
+	if got := out.String(); got != want {
+		t.Errorf("Diff wrote\n%s\nwant\n%s", got, want)
+	}
+	if n != 3 {
+		t.Errorf("Diff returned %d; want 3", n)
+	}
+}
+
+func TestDiffDanglingPlugs(t *testing.T) {
+	a := NewMachine(MachineConn{Output: ioutil.Discard})
+	b := NewMachine(MachineConn{Output: ioutil.Discard})
+	a.Exec(ioutil.Discard, "p a1.5o 1-1")
+	a.Exec(ioutil.Discard, "p 1-2 a2.5i")
+	b.Exec(ioutil.Discard, "p a1.5o 1-3")
+	b.Exec(ioutil.Discard, "p 1-2 a2.5i")
+
+	var out bytes.Buffer
+	n := Diff(&out, a, b)
+	want := "p a1.5o 1-1 removed\n" +
+		"p a1.5o 1-3 added\n"
+	if got := out.String(); got != want {
+		t.Errorf("Diff wrote\n%s\nwant\n%s", got, want)
+	}
+	if n != 2 {
+		t.Errorf("Diff returned %d; want 2", n)
+	}
+}
+
+func TestDiffLeavesMachinesUnchanged(t *testing.T) {
+	a := NewMachine(MachineConn{Output: ioutil.Discard})
+	b := NewMachine(MachineConn{Output: ioutil.Discard})
+	a.Exec(ioutil.Discard, "s debug.assert.x a1~Pxxxxxxxxxx")
+
+	var out bytes.Buffer
+	if n := Diff(&out, a, b); n != 1 {
+		t.Errorf("Diff returned %d; want 1", n)
+	}
+	if names := b.debugger.switchNames(); len(names) != 0 {
+		t.Errorf("Diff allocated %v on b", names)
+	}
+}
@@ -0,0 +1,245 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// fault is a simulated hardware failure injected with the fault command.
+// Faults in accumulators and function tables are applied by the units as they
+// are clocked; the rest filter pulses on their way from particular jacks.
+type fault struct {
+	jack   *Jack         // Output whose pulses are affected
+	trunk  string        // Tray whose pulses are affected
+	filter func(int) int // Applied to affected pulses
+}
+
+var (
+	decadeFault = regexp.MustCompile(`^a(\d+)\.decade(\d+)$`)
+	carryFault  = regexp.MustCompile(`^a(\d+)\.carry(\d+)$`)
+)
+
+// Fault injects a failure described by f, the arguments of a fault command:
+//
+//	aN.decadeD stuck V     decade D (1 is least significant) always reads V
+//	aN.carryD drop         carries out of decade D are lost
+//	fN.switch reads V      function table switch reads as V
+//	jack dead              pulses from an output or on a tray are lost
+//	jack drop P [seed=S]   each pulse from an output or on a tray is lost
+//	                       with probability P
+//
+// Pulses are lost in the cabling, so traces and taps on the sending output
+// still see them.
+func (m *Machine) Fault(f []string) error {
+	if len(f) < 2 {
+		return fmt.Errorf("missing fault type")
+	}
+	target, kind := f[0], f[1]
+	args := f[2:]
+	switch {
+	case kind == "stuck" && len(args) == 1:
+		p := decadeFault.FindStringSubmatch(target)
+		if p == nil {
+			return fmt.Errorf("expected aN.decadeD for stuck fault")
+		}
+		a, err := m.faultAccumulator(p[1])
+		if err != nil {
+			return err
+		}
+		decade, _ := strconv.Atoi(p[2])
+		digit, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid digit %s", args[0])
+		}
+		if err := m.u.Accumulator[a].SetStuckDecade(decade, digit); err != nil {
+			return err
+		}
+	case kind == "drop" && len(args) == 0:
+		p := carryFault.FindStringSubmatch(target)
+		if p == nil {
+			return fmt.Errorf("expected aN.carryD for carry fault")
+		}
+		a, err := m.faultAccumulator(p[1])
+		if err != nil {
+			return err
+		}
+		decade, _ := strconv.Atoi(p[2])
+		if err := m.u.Accumulator[a].SetDroppedCarry(decade); err != nil {
+			return err
+		}
+	case kind == "reads" && len(args) == 1:
+		p := strings.SplitN(target, ".", 2)
+		n := 0
+		if len(p) == 2 && len(p[0]) == 2 && p[0][0] == 'f' {
+			n, _ = strconv.Atoi(p[0][1:])
+		}
+		if !(n >= 1 && n <= 3) {
+			return fmt.Errorf("expected fN.switch for reads fault")
+		}
+		if err := m.u.Ft[n-1].AddReadFault(p[1], args[0]); err != nil {
+			return err
+		}
+	case kind == "dead" && len(args) == 0:
+		if err := m.addPulseFault(target, func(int) int { return 0 }); err != nil {
+			return err
+		}
+	case kind == "drop" && (len(args) == 1 || len(args) == 2):
+		prob, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || !(prob >= 0 && prob <= 1) {
+			return fmt.Errorf("invalid probability %s", args[0])
+		}
+		seed := int64(1)
+		if len(args) == 2 {
+			if !strings.HasPrefix(args[1], "seed=") {
+				return fmt.Errorf("expected seed=S, got %s", args[1])
+			}
+			seed, err = strconv.ParseInt(strings.TrimPrefix(args[1], "seed="), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid seed %s", args[1])
+			}
+		}
+		if err := m.addPulseFault(target, dropPulses(prob, rand.New(rand.NewSource(seed)))); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid fault %s", strings.Join(f, " "))
+	}
+	m.faultSpecs = append(m.faultSpecs, strings.Join(f, " "))
+	m.updateFaults()
+	return nil
+}
+
+// ClearFaults removes all injected faults.
+func (m *Machine) ClearFaults() {
+	for _, a := range m.u.Accumulator {
+		a.ClearFaults()
+	}
+	for _, f := range m.u.Ft {
+		f.ClearFaults()
+	}
+	m.faults = nil
+	m.faultSpecs = nil
+	m.updateFaults()
+}
+
+// addPulseFault applies filter to pulses sent by the output or on the tray
+// named target.
+func (m *Machine) addPulseFault(target string, filter func(int) int) error {
+	jack, _, err := m.findJack(target, 0)
+	if err != nil {
+		return err
+	}
+	f := fault{filter: filter}
+	if isTrayName(jack.Name) {
+		f.trunk = jack.Name
+	} else {
+		f.jack = jack
+	}
+	m.faults = append(m.faults, f)
+	return nil
+}
+
+// dropPulses returns a filter which loses each pulse in a value (one per
+// digit and sign line) with probability prob.
+func dropPulses(prob float64, r *rand.Rand) func(int) int {
+	return func(val int) int {
+		for bit := 0; val>>uint(bit) != 0; bit++ {
+			if val&(1<<uint(bit)) != 0 && r.Float64() < prob {
+				val &^= 1 << uint(bit)
+			}
+		}
+		return val
+	}
+}
+
+func (m *Machine) faultAccumulator(n string) (int, error) {
+	a, _ := strconv.Atoi(n)
+	if !(a >= 1 && a <= 20) {
+		return 0, fmt.Errorf("invalid accumulator %s", n)
+	}
+	return a - 1, nil
+}
+
+// updateFaults installs pulse filters for jack and trunk faults, and has
+// the cycling unit apply unit faults if there are any.  A trunk fault filters
+// pulses from each output plugged into the trunk to the receivers it reaches
+// through the trunk, so it must be reinstalled whenever cabling changes.
+func (m *Machine) updateFaults() {
+	faulty := false
+	for _, a := range m.u.Accumulator {
+		faulty = faulty || a.Faulty()
+	}
+	for _, f := range m.u.Ft {
+		faulty = faulty || f.Faulty()
+	}
+	m.cycle.SetFaulty(faulty)
+
+	for _, j := range m.ratsNest.Jacks() {
+		j.ClearFilters()
+	}
+	for _, f := range m.faults {
+		if f.jack != nil {
+			if len(f.jack.FinalReceivers()) != 0 {
+				f.jack.Filter(f.jack.FinalReceivers(), f.filter)
+			}
+			continue
+		}
+		trunk, _, _ := m.findJack(f.trunk, 0)
+		reached := receiversThrough(trunk)
+		for _, j := range m.ratsNest.Jacks() {
+			if isRoutingJack(j) || !routesTo(j, trunk) {
+				continue
+			}
+			var to []*Jack
+			for _, r := range j.FinalReceivers() {
+				if reached[r] {
+					to = append(to, r)
+				}
+			}
+			if len(to) != 0 {
+				j.Filter(to, f.filter)
+			}
+		}
+	}
+}
+
+// receiversThrough returns the non-routing jacks which pulses on the routing
+// jack j reach.
+func receiversThrough(j *Jack) map[*Jack]bool {
+	reached := make(map[*Jack]bool)
+	visited := make(map[*Jack]bool)
+	var visit func(*Jack)
+	visit = func(j *Jack) {
+		for _, r := range j.Receivers {
+			if !isRoutingJack(r) {
+				reached[r] = true
+			} else if !visited[r] {
+				visited[r] = true
+				visit(r)
+			}
+		}
+	}
+	visit(j)
+	return reached
+}
+
+func (m *Machine) doFault(w io.Writer, f []string) {
+	switch {
+	case len(f) == 1:
+		for _, spec := range m.faultSpecs {
+			fmt.Fprintf(w, "fault %s\n", spec)
+		}
+	case len(f) == 2 && f[1] == "clear":
+		m.ClearFaults()
+	default:
+		if err := m.Fault(f[1:]); err != nil {
+			fmt.Fprintf(w, "fault: %s\n", err)
+		}
+	}
+}
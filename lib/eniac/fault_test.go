@@ -0,0 +1,127 @@
+package eniac
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFault(t *testing.T) {
+	tests := []struct {
+		fault string
+		want  string
+	}{
+		{"", "P 0000000010"},
+		{"fault a1.decade1 stuck 7", "P 0000000007"},
+		{"fault a1.carry1 drop", "P 0000000000"},
+		{"fault a2.A dead", "P 0000000009"},
+		{"fault 1 drop 1", "P 0000000009"},
+		{"fault 1 drop 0 seed=42", "P 0000000010"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		m := NewMachine(MachineConn{Output: &out})
+		for _, command := range []string{
+			test.fault,
+			"set a1 9",
+			"set a2 1",
+			"p i.Io 1-1",
+			"p 1-1 a1.1i",
+			"p 1-1 a2.1i",
+			"s a1.op1 α",
+			"s a2.op1 A",
+			"p a2.A 1",
+			"p 1 a1.α",
+			"b i",
+		} {
+			m.Exec(&out, command)
+		}
+		m.Cycle().SetTestMode()
+		m.Run(10)
+		if out.Len() != 0 {
+			t.Errorf("%q: unexpected output %q", test.fault, out.String())
+		}
+		if got := string(m.Units().Accumulator[0].Value()); got != test.want {
+			t.Errorf("%q: a1 = %s; want %s", test.fault, got, test.want)
+		}
+	}
+}
+
+func TestFaultCommand(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Exec(&out, "s f1.RA3L2 4")
+	m.Exec(&out, "fault f1.RA3L2 reads 5")
+	m.Exec(&out, "fault A-1 drop 0.001 seed=42")
+	m.Exec(&out, "fault a3.decade11 stuck 7")
+	m.Exec(&out, "fault a3.5o")
+	m.Exec(&out, "fault")
+	want := "fault: invalid decade 11\n" +
+		"fault: missing fault type\n" +
+		"fault f1.RA3L2 reads 5\n" +
+		"fault A-1 drop 0.001 seed=42\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if sw, _ := m.findSwitch("f1.RA3L2"); sw.Get() != "4" {
+		t.Errorf("f1.RA3L2 = %s; want 4", sw.Get())
+	}
+	out.Reset()
+	m.Exec(&out, "fault clear")
+	m.Exec(&out, "fault")
+	if out.Len() != 0 {
+		t.Errorf("faults remain after clear: %q", out.String())
+	}
+}
+
+func TestTrunkFaultSparesOtherTrunks(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	for _, command := range []string{
+		"set a2 1",
+		"p i.Io 1-1",
+		"p 1-1 a1.1i",
+		"p 1-1 a2.1i",
+		"p 1-1 a3.1i",
+		"s a1.op1 α",
+		"s a2.op1 A",
+		"s a3.op1 α",
+		"p a2.A 1",
+		"p a2.A 2",
+		"p 1 a1.α",
+		"p 2 a3.α",
+		"fault 1 dead",
+		"b i",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Cycle().SetTestMode()
+	m.Run(10)
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if got := string(m.Units().Accumulator[0].Value()); got != "P 0000000000" {
+		t.Errorf("a1 = %s; want P 0000000000", got)
+	}
+	if got := string(m.Units().Accumulator[2].Value()); got != "P 0000000001" {
+		t.Errorf("a3 = %s; want P 0000000001", got)
+	}
+
+	// The fault is reinstalled after replugging and goes away when cleared.
+	m.Exec(&out, "u 2 a3.α")
+	m.Exec(&out, "p 2 a3.β")
+	m.Exec(&out, "s a3.op1 β")
+	m.Exec(&out, "b i")
+	m.Run(10)
+	if got := string(m.Units().Accumulator[0].Value()); got != "P 0000000000" {
+		t.Errorf("after replugging a1 = %s; want P 0000000000", got)
+	}
+	if got := string(m.Units().Accumulator[2].Value()); got != "P 0000000002" {
+		t.Errorf("after replugging a3 = %s; want P 0000000002", got)
+	}
+	m.Exec(&out, "fault clear")
+	m.Exec(&out, "b i")
+	m.Run(10)
+	if got := string(m.Units().Accumulator[0].Value()); got != "P 0000000001" {
+		t.Errorf("after clear a1 = %s; want P 0000000001", got)
+	}
+}
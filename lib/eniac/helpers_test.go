@@ -0,0 +1,30 @@
+package eniac
+
+import "bytes"
+
+// newMachineWith returns a new machine set up by running commands.
+func newMachineWith(out *bytes.Buffer, commands ...string) *Machine {
+	m := NewMachine(MachineConn{Output: out})
+	for _, command := range commands {
+		m.Exec(out, command)
+	}
+	return m
+}
+
+// newCounter returns a machine where a2 counts up by one each add cycle for
+// 9 add cycles.
+func newCounter(out *bytes.Buffer) *Machine {
+	return newMachineWith(out,
+		"set a1 1",
+		"p i.Io 1-1",
+		"p 1-1 a1.5i",
+		"s a1.op5 A",
+		"s a1.rp5 9",
+		"p 1-1 a2.5i",
+		"s a2.op5 α",
+		"s a2.rp5 9",
+		"p a1.A 1",
+		"p 1 a2.α",
+		"b i",
+	)
+}
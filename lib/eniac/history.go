@@ -0,0 +1,187 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// Checkpoints for reverse stepping are taken every historyInterval add
+// cycles, and the most recent historyLength are kept.
+const (
+	historyInterval = 1000
+	historyLength   = 100
+)
+
+type checkpoint struct {
+	addCycle  int64
+	cardsRead int
+	state     []byte
+}
+
+// history keeps periodic snapshots of a running machine so that it can be
+// wound back to an earlier add cycle.  Snapshots are only taken once history
+// is enabled with back on, and the initiate unit keeps the cards read since
+// the oldest one to read them again.
+type history struct {
+	enabled     bool
+	checkpoints []checkpoint
+}
+
+// EnableHistory starts or stops taking checkpoints for Back.  Stopping drops
+// any checkpoints taken so far.
+func (m *Machine) EnableHistory(enabled bool) {
+	m.history.enabled = enabled
+	m.resetHistory()
+	m.u.Initiate.KeepCards(enabled)
+	if enabled {
+		m.cycle.Io.Record = m.record
+	} else {
+		m.cycle.Io.Record = nil
+	}
+}
+
+// resetHistory drops checkpoints when machine state is replaced other than by
+// running, since they belong to a different timeline.
+func (m *Machine) resetHistory() {
+	m.history.checkpoints = nil
+	m.u.Initiate.DropCardsBefore(m.u.Initiate.CardsRead())
+}
+
+// record is called by the cycle unit at each add cycle boundary.
+func (m *Machine) record() {
+	cycle := m.cycle.AddCycle
+	if cycle%historyInterval != 0 {
+		return
+	}
+	h := &m.history
+	if n := len(h.checkpoints); n > 0 && h.checkpoints[n-1].addCycle >= cycle {
+		return
+	}
+	h.checkpoints = append(h.checkpoints, checkpoint{cycle, m.u.Initiate.CardsRead(), m.Snapshot()})
+	if len(h.checkpoints) > historyLength {
+		h.checkpoints = h.checkpoints[1:]
+	}
+	m.u.Initiate.DropCardsBefore(h.checkpoints[0].cardsRead)
+}
+
+// Back rewinds the machine by n add cycles, by restoring the closest earlier
+// checkpoint and replaying forward.  Changes made with commands since that
+// checkpoint (such as s) are not replayed.
+func (m *Machine) Back(n int64) error {
+	if !m.history.enabled {
+		return fmt.Errorf("history is off, use back on")
+	}
+	target := m.cycle.AddCycle - n
+	if n < 0 || target < 0 {
+		return fmt.Errorf("can't go back to add cycle %d", target)
+	}
+	h := &m.history
+	i := len(h.checkpoints) - 1
+	for i >= 0 && h.checkpoints[i].addCycle > target {
+		i--
+	}
+	if i < 0 {
+		return fmt.Errorf("no checkpoint at or before add cycle %d", target)
+	}
+	cp := h.checkpoints[i]
+	if err := m.restoreState(cp.state); err != nil {
+		return err
+	}
+	h.checkpoints = h.checkpoints[:i+1]
+	m.replay(target - cp.addCycle)
+	return nil
+}
+
+// replay runs n add cycles that have been run before without printing cards,
+// stopping for the debugger or recording them again in traces, logs,
+// profiles or coverage.
+func (m *Machine) replay(n int64) {
+	saved := m.debugger.Io
+	m.debugger.Io.Output = ioutil.Discard
+	m.debugger.Io.Stop = func() {}
+	m.debugger.Io.Quit = func() {}
+	m.replaying = true
+	m.updateTaps()
+	m.updateAddCycleHook()
+	if m.tracer != nil {
+		m.tracer.paused = true
+	}
+	m.u.Initiate.SetReplaying(true)
+	m.cycle.StepNAddCycles(int(n))
+	m.u.Initiate.SetReplaying(false)
+	if m.tracer != nil {
+		m.tracer.paused = false
+	}
+	m.replaying = false
+	m.updateTaps()
+	m.updateAddCycleHook()
+	for _, w := range m.watches {
+		w.last, _ = w.value()
+		w.hit = w.test(w.last)
+	}
+	m.debugger.Io = saved
+}
+
+// pausableTracer forwards to the machine's tracer except while replaying, so
+// that traces don't record add cycles twice or go back in time.
+//
+// While declare is set, values logged are passed to it instead, so that
+// running register callbacks names every register.
+type pausableTracer struct {
+	Tracer
+	paused  bool
+	declare func(kind, name string, bits int)
+}
+
+func (t *pausableTracer) AdvanceTimestep() {
+	if !t.paused {
+		t.Tracer.AdvanceTimestep()
+	}
+}
+
+func (t *pausableTracer) UpdateValues() {
+	if !t.paused {
+		t.Tracer.UpdateValues()
+	}
+}
+
+func (t *pausableTracer) LogValue(name string, bits int, value int64) {
+	if t.declare != nil {
+		t.declare("reg", name, bits)
+	} else if !t.paused {
+		t.Tracer.LogValue(name, bits, value)
+	}
+}
+
+func (t *pausableTracer) LogPulse(name string, bits int, value int64) {
+	if !t.paused {
+		t.Tracer.LogPulse(name, bits, value)
+	}
+}
+
+func (m *Machine) doBack(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "back syntax: back cycles|on|off")
+		return
+	}
+	switch f[1] {
+	case "on":
+		m.EnableHistory(true)
+		return
+	case "off":
+		m.EnableHistory(false)
+		return
+	}
+	n, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil || n < 0 {
+		fmt.Fprintf(w, "Invalid cycle count %s\n", f[1])
+		return
+	}
+	if err := m.Back(n); err != nil {
+		fmt.Fprintf(w, "back: %s\n", err)
+	}
+}
@@ -0,0 +1,120 @@
+package eniac
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackCommands(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "back 1")
+	m.Exec(&out, "back on")
+	m.Run(5)
+	m.Exec(&out, "set a3 1")
+	m.Exec(&out, "back 1")
+	want := "back: history is off, use back on\n" +
+		"back: no checkpoint at or before add cycle 4\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBackAfterRestore(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "back on")
+	start := m.Snapshot()
+	m.Run(2500)
+	if err := m.Restore(start); err != nil {
+		t.Fatal(err)
+	}
+	m.Run(1500)
+	var cycles []int64
+	for _, cp := range m.history.checkpoints {
+		cycles = append(cycles, cp.addCycle)
+	}
+	if len(cycles) != 2 || cycles[0] != 0 || cycles[1] != 1000 {
+		t.Errorf("checkpoints at %v; want [0 1000]", cycles)
+	}
+}
+
+func TestBackReplayNotTraced(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "back on")
+	m.Exec(&out, "ts pc")
+	m.Exec(&out, "profile start")
+	m.Run(8)
+	time := m.waves.curTime
+	m.Exec(&out, "back 5")
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if m.waves.curTime != time {
+		t.Errorf("trace time %d after back; want %d", m.waves.curTime, time)
+	}
+	var log bytes.Buffer
+	m.textLog.WriteLog(&log)
+	if got, want := log.String(), "1 i.Io -> 1-1 -> {a1.5i, a2.5i}\n"; got != want {
+		t.Errorf("got log\n%s\nwant\n%s", got, want)
+	}
+	if got := m.profile.Lines["1-1"]; got != 1 {
+		t.Errorf("1-1 profiled %d times; want 1", got)
+	}
+	if got := m.profile.AddCycles; got != 8 {
+		t.Errorf("profiled %d add cycles; want 8", got)
+	}
+}
+
+func TestBackKeepsCardsSinceOldestCheckpoint(t *testing.T) {
+	var deck strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&deck, "%05d\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "deck")
+	if err := ioutil.WriteFile(path, []byte(deck.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	m := newMachineWith(&out,
+		"f r "+path,
+		"p i.Io 1-1",
+		"p 1-1 i.Ri",
+		"p 1-1 i.Rl",
+		"p i.Ro 1-1",
+		"b i",
+	)
+	m.Cycle().SetTestMode()
+	init := m.u.Initiate
+	m.Run(10000)
+	if init.CardsRead() == 0 || init.KeptCards() != 0 {
+		t.Errorf("read %d cards keeping %d with history off; want some, keeping none",
+			init.CardsRead(), init.KeptCards())
+	}
+	m.Exec(&out, "back on")
+	m.Run(150000)
+	if want := init.CardsRead() - m.history.checkpoints[0].cardsRead; init.KeptCards() != want {
+		t.Errorf("kept %d cards; want %d read since the oldest checkpoint", init.KeptCards(), want)
+	}
+	state := m.Snapshot()
+	m.Run(5000)
+	m.Exec(&out, "back 5000")
+	if !bytes.Equal(m.Snapshot(), state) {
+		t.Errorf("state after back differs")
+	}
+	m.Exec(&out, "back off")
+	if init.KeptCards() != 0 {
+		t.Errorf("kept %d cards after back off", init.KeptCards())
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,132 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lockstepPulses is how many program pulses Lockstep shows from each machine
+// when they differ.
+const lockstepPulses = 20
+
+// LockstepSide is one of the machines compared by Lockstep.
+type LockstepSide struct {
+	Name    string
+	Machine *Machine
+
+	cards []string // Printed so far
+}
+
+// Lockstep runs machines a and b together for up to n add cycles, stopping at
+// the end of the first add cycle where their accumulators, master programmer
+// decades or printed cards differ.  If printsOnly is set, only the cards
+// printed are compared, regardless of when they are printed.  On a difference,
+// writes both machines' states and last few program pulses to w and returns
+// false.
+func Lockstep(w io.Writer, a, b *LockstepSide, n int, printsOnly bool) bool {
+	sides := []*LockstepSide{a, b}
+	for _, s := range sides {
+		s.watch()
+	}
+	matched := 0 // Cards known to be the same
+	for cycle := 0; cycle < n; cycle++ {
+		for _, s := range sides {
+			s.Machine.Run(1)
+			s.Machine.textLog.trim(lockstepPulses)
+		}
+		var diffs []string
+		if !printsOnly {
+			sa, sb := a.Machine.architecturalState(), b.Machine.architecturalState()
+			for i := range sa {
+				if sa[i] != sb[i] {
+					diffs = append(diffs, fmt.Sprintf("%s | %s", sa[i], sb[i]))
+				}
+			}
+		}
+		diffs = append(diffs, diffCards(a.cards, b.cards, matched, !printsOnly)...)
+		if len(diffs) != 0 {
+			fmt.Fprintf(w, "%s and %s differ at add cycle %d:\n", a.Name, b.Name, a.Machine.AddCycle())
+			for _, d := range diffs {
+				fmt.Fprintf(w, "  %s\n", d)
+			}
+			for _, s := range sides {
+				s.writeState(w)
+			}
+			return false
+		}
+		if matched = len(a.cards); len(b.cards) < matched {
+			matched = len(b.cards)
+		}
+	}
+	if printsOnly && len(a.cards) != len(b.cards) {
+		fmt.Fprintf(w, "%s printed %d cards and %s printed %d\n", a.Name, len(a.cards), b.Name, len(b.cards))
+		return false
+	}
+	return true
+}
+
+// watch starts recording s's printed cards and program pulses.
+func (s *LockstepSide) watch() {
+	m := s.Machine
+	print := m.u.Initiate.Io.Print
+	m.u.Initiate.Io.Print = func() string {
+		card := print()
+		s.cards = append(s.cards, card)
+		return card
+	}
+	m.textLog = &traceLog{causes: true}
+	m.updateTaps()
+}
+
+func (s *LockstepSide) writeState(w io.Writer) {
+	fmt.Fprintf(w, "%s last program pulses:\n", s.Name)
+	lines := s.Machine.textLog.lines
+	if len(lines) > lockstepPulses {
+		lines = lines[len(lines)-lockstepPulses:]
+	}
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	fmt.Fprintf(w, "%s state:", s.Name)
+	s.Machine.DumpAll(w)
+}
+
+// architecturalState returns the values a program can observe, one per line.
+func (m *Machine) architecturalState() []string {
+	var state []string
+	for i, a := range m.u.Accumulator {
+		state = append(state, fmt.Sprintf("a%d %s", i+1, a.Value()))
+	}
+	for n := 20; n >= 1; n-- {
+		state = append(state, fmt.Sprintf("p.d%d %d", n, m.u.Mp.Decade(n)))
+	}
+	return state
+}
+
+// diffCards describes where cards printed by a and b differ, comparing from
+// card from onward.  Unless exact is set, a machine which has printed fewer
+// cards may catch up later.
+func diffCards(a, b []string, from int, exact bool) []string {
+	var diffs []string
+	for i := from; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			diffs = append(diffs, fmt.Sprintf("card %d %s | %s", i+1, a[i], b[i]))
+		}
+	}
+	if exact && len(a) != len(b) {
+		extra := a[len(b):]
+		if len(b) > len(a) {
+			extra = b[len(a):]
+		}
+		diffs = append(diffs, fmt.Sprintf("printed %d | %d cards: %s", len(a), len(b), strings.Join(extra, ", ")))
+	}
+	return diffs
+}
+
+// trim discards all but the last n lines logged.
+func (t *traceLog) trim(n int) {
+	if len(t.lines) > 2*n {
+		t.lines = append([]string(nil), t.lines[len(t.lines)-n:]...)
+	}
+}
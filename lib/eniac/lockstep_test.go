@@ -0,0 +1,58 @@
+package eniac
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLockstep(t *testing.T) {
+	var out bytes.Buffer
+	a := &LockstepSide{Name: "a", Machine: newCounter(&out)}
+	b := &LockstepSide{Name: "b", Machine: newCounter(&out)}
+	b.Machine.Exec(&out, "set a1 2")
+	for _, s := range []*LockstepSide{a, b} {
+		s.Machine.Cycle().SetTestMode()
+	}
+	var report bytes.Buffer
+	if Lockstep(&report, a, b, 20, false) {
+		t.Fatalf("Lockstep found no difference")
+	}
+	got := report.String()
+	want := "a and b differ at add cycle 1:\n" +
+		"  a1 P 0000000001 | a1 P 0000000002\n" +
+		"a last program pulses:\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("got\n%s\nwant prefix\n%s", got, want)
+	}
+	if !strings.Contains(got, "b state:\n") {
+		t.Errorf("missing state for b\n%s", got)
+	}
+}
+
+func TestLockstepSame(t *testing.T) {
+	var out bytes.Buffer
+	a := &LockstepSide{Name: "a", Machine: newCounter(&out)}
+	b := &LockstepSide{Name: "b", Machine: newCounter(&out)}
+	for _, s := range []*LockstepSide{a, b} {
+		s.Machine.Cycle().SetTestMode()
+	}
+	var report bytes.Buffer
+	if !Lockstep(&report, a, b, 20, false) {
+		t.Errorf("Lockstep found a difference\n%s", report.String())
+	}
+}
+
+func TestDiffCards(t *testing.T) {
+	a := []string{"1", "2", "3"}
+	b := []string{"1", "x"}
+	if got := diffCards(a, b, 1, false); len(got) != 1 || got[0] != "card 2 2 | x" {
+		t.Errorf("diffCards = %q", got)
+	}
+	if got := diffCards(a[:2], b[:1], 0, true); len(got) != 1 || got[0] != "printed 2 | 1 cards: 2" {
+		t.Errorf("diffCards exact = %q", got)
+	}
+	if got := diffCards(a[:2], b[:1], 0, false); len(got) != 0 {
+		t.Errorf("diffCards = %q", got)
+	}
+}
@@ -0,0 +1,268 @@
+package eniac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/jeredw/eniacsim/lib"
+	"github.com/jeredw/eniacsim/lib/units"
+)
+
+// Machine is a complete simulated ENIAC: the clocked units, the printer and
+// debugger, and all of the trays, adapters and cables wiring them together.
+//
+// Machines share no state, so a process may create and drive several
+// independent machines.  A single Machine is not safe for concurrent use.
+type Machine struct {
+	Io MachineConn // Connections to the host
+
+	cycle     *units.Cycle
+	u         *units.ClockedUnits
+	printer   *units.Printer
+	debugger  *Debugger
+	trays     *Trays
+	adapters  *Adapters
+	pulseAmps *PulseAmps
+
+	ratsNest    *RatsNest
+	tracer      *pausableTracer // Attached to units, if tracing
+	waves       *wavedump
+	stream      *vcdStream
+	traceFilter *traceFilter
+	textLog     *traceLog
+	timeline    *timeline
+	profile     *profile
+	coverage    *coverage
+	capture     *capture
+	history     history
+	replaying   bool // Replaying add cycles for Back
+
+	unknownSwitches []string // Names rejected by s, for Check
+
+	watches   []*watchpoint
+	nextWatch int
+
+	breakpoints    []*breakpoint
+	nextBreakpoint int
+	tapped         []*Jack // Jacks with debugging taps installed
+
+	faults     []fault
+	faultSpecs []string // Arguments of each fault command, for listing
+
+	perfCycles int64
+	perfTime   time.Duration
+}
+
+// MachineConn defines connections from a Machine to the host environment.
+type MachineConn struct {
+	Output io.Writer   // Printed cards and debugger messages (default stdout)
+	Ppunch chan string // If set, receives a copy of every printed card
+
+	StepAndVerifyVM func()        // Cross-validate checkpoint with vm
+	StepAheadVM     func(n int64) // Step ahead up to cycle n with vm
+	Quit            func()        // Called on debug.quit (default is to stop)
+}
+
+// NewMachine returns a Machine with all units cleared and nothing plugged.
+func NewMachine(io MachineConn) *Machine {
+	if io.Output == nil {
+		io.Output = os.Stdout
+	}
+	m := &Machine{Io: io}
+	m.ratsNest = NewRatsNest()
+	m.trays = NewTrays()
+	m.adapters = NewAdapters()
+	m.pulseAmps = NewPulseAmps()
+	m.debugger = NewDebugger(DebuggerConn{
+		Probe:    m.probe,
+		Output:   io.Output,
+		Stop:     func() { m.stop() },
+		Quit:     func() { m.quit() },
+		AddCycle: func() int64 { return m.cycle.AddCycle },
+	})
+	m.cycle = units.NewCycle(units.CycleConn{})
+	u := &units.ClockedUnits{}
+	m.u = u
+	u.Initiate = units.NewInitiate(units.InitiateConn{
+		Ppunch: io.Ppunch,
+		Output: io.Output,
+	})
+	u.Mp = units.NewMp()
+	u.Divsr = units.NewDivsr()
+	u.Multiplier = units.NewMultiplier()
+	u.Constant = units.NewConstant()
+	m.printer = units.NewPrinter()
+	for i := 0; i < 3; i++ {
+		u.Ft[i] = units.NewFt(i)
+	}
+	for i := 0; i < 20; i++ {
+		u.Accumulator[i] = units.NewAccumulator(i)
+	}
+	u.TenStepper = units.NewAuxStepper("st", 10)
+	u.FtSelector = units.NewAuxStepper("sft", 6)
+	u.OrderSelector = units.NewOrderSelector()
+	for i := 0; i < 2; i++ {
+		u.PmDiscriminator[i] = units.NewAuxStepper(fmt.Sprintf("pm%d", i+1), 2)
+	}
+	for i := 0; i < 2; i++ {
+		u.JkSelector[i] = units.NewAuxStepper(fmt.Sprintf("sjk%d", i+1), 6)
+	}
+
+	clearedUnits := []Cleared{u.Mp, u.Divsr}
+	var programUnits []ProgramCleared
+	for i := 0; i < 20; i++ {
+		clearedUnits = append(clearedUnits, u.Accumulator[i])
+		programUnits = append(programUnits, u.Accumulator[i])
+	}
+	for i := 0; i < 3; i++ {
+		programUnits = append(programUnits, u.Ft[i])
+	}
+
+	m.cycle.Io.Units = u
+	m.cycle.Io.SelectiveClear = func() bool { return u.Initiate.SelectiveClear() }
+	m.cycle.Io.StepAndVerifyVM = func() {
+		if m.Io.StepAndVerifyVM != nil {
+			m.Io.StepAndVerifyVM()
+		}
+	}
+	m.cycle.Io.StepAheadVM = func(cycle int64) {
+		if m.Io.StepAheadVM != nil {
+			m.Io.StepAheadVM(cycle)
+		}
+	}
+	u.Initiate.Io.Units = clearedUnits
+	u.Initiate.Io.Programs = programUnits
+	u.Initiate.Io.AddCycle = func() int64 { return m.cycle.AddCycle }
+	u.Initiate.Io.Stepping = func() bool { return m.cycle.Stepping() }
+	u.Initiate.Io.ReadCard = func(s string) {
+		m.logCard("read", s)
+		u.Constant.ReadCard(s)
+	}
+	u.Initiate.Io.Print = func() string {
+		s := m.printer.Print()
+		m.logCard("print", s)
+		return s
+	}
+	u.Divsr.Io.Quotient = u.Accumulator[2-1]
+	u.Divsr.Io.Numerator = u.Accumulator[3-1]
+	u.Divsr.Io.Denominator = u.Accumulator[5-1]
+	u.Divsr.Io.Shift = u.Accumulator[7-1]
+	u.Multiplier.Io.Ier = u.Accumulator[9-1]
+	u.Multiplier.Io.Icand = u.Accumulator[10-1]
+	u.Multiplier.Io.Lhpp = u.Accumulator[11-1]
+	u.Multiplier.Io.Rhpp = u.Accumulator[13-1]
+	m.printer.Io.MpPrinterDecades = func() string { return u.Mp.PrinterDecades() }
+	for i := 0; i < 20; i++ {
+		m.printer.Io.Accumulator[i] = u.Accumulator[i]
+	}
+	return m
+}
+
+// PowerOn sets the state the machine starts in.  NewMachine clears every
+// unit, which is mode "zero".  Mode "random:seed" instead puts accumulators,
+// the master programmer and function tables in a pseudo-random state
+// determined by seed, as the real machine was when switched on, so only
+// programs which begin with an initial clear (b c) behave predictably.  Program
+// flip-flops start set at random too, so a configuration which starts programs
+// without clearing first may reach a conflict the units can't model, which
+// panics.
+func (m *Machine) PowerOn(mode string) error {
+	if mode == "zero" {
+		return nil
+	}
+	if !strings.HasPrefix(mode, "random:") {
+		return fmt.Errorf("invalid power on mode %s", mode)
+	}
+	seed, err := strconv.ParseInt(strings.TrimPrefix(mode, "random:"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid power on seed %s", mode)
+	}
+	r := rand.New(rand.NewSource(seed))
+	for _, a := range m.u.Accumulator {
+		a.PowerOn(r)
+	}
+	m.u.Mp.PowerOn(r)
+	for _, f := range m.u.Ft {
+		f.PowerOn(r)
+	}
+	return nil
+}
+
+// Load runs each command in the configuration file at path, writing any
+// command output to the machine's output.  Checkpoints kept for Back and
+// switch names rejected by earlier loads are dropped.
+func (m *Machine) Load(path string) error {
+	m.resetHistory()
+	m.unknownSwitches = nil
+	fd, err := os.Open(path)
+	if err != nil {
+		fd, err = os.Open("programs/" + path)
+		if err != nil {
+			return err
+		}
+	}
+	defer fd.Close()
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		if m.Exec(m.Io.Output, sc.Text()) < 0 {
+			return nil
+		}
+	}
+	return sc.Err()
+}
+
+// Step advances the clock by one pulse or one add cycle, depending on the
+// cy.op switch.
+func (m *Machine) Step() {
+	m.cycle.Step()
+}
+
+// Run advances the simulation by n add cycles.  Returns true if stopped early
+// by the debugger.
+func (m *Machine) Run(n int) bool {
+	return m.cycle.StepNAddCycles(n)
+}
+
+// AddCycle returns the number of add cycles simulated so far.
+func (m *Machine) AddCycle() int64 {
+	return m.cycle.AddCycle
+}
+
+// Cycle returns the machine's cycling unit.
+func (m *Machine) Cycle() *units.Cycle {
+	return m.cycle
+}
+
+// Units returns the machine's clocked units.
+func (m *Machine) Units() *units.ClockedUnits {
+	return m.u
+}
+
+// Printer returns the machine's printer.
+func (m *Machine) Printer() *units.Printer {
+	return m.printer
+}
+
+// RatsNest returns the set of all plugged connections.
+func (m *Machine) RatsNest() *RatsNest {
+	return m.ratsNest
+}
+
+// DumpAll writes the state of every unit to w, as for the D command.
+func (m *Machine) DumpAll(w io.Writer) {
+	m.doDumpAll(w)
+}
+
+func (m *Machine) quit() {
+	if m.Io.Quit != nil {
+		m.Io.Quit()
+		return
+	}
+	m.cycle.Stop()
+}
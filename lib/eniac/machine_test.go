@@ -0,0 +1,299 @@
+package eniac
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndependentMachines(t *testing.T) {
+	var out1, out2 bytes.Buffer
+	m1 := NewMachine(MachineConn{Output: &out1})
+	m2 := NewMachine(MachineConn{Output: &out2})
+	m1.Exec(&out1, "set a1 42")
+	m2.Exec(&out2, "set a1 -7")
+	if got := string(m1.Units().Accumulator[0].Value()); got != "P 0000000042" {
+		t.Errorf("m1 a1 = %s; want P 0000000042", got)
+	}
+	if got := string(m2.Units().Accumulator[0].Value()); got != "M 0000000007" {
+		t.Errorf("m2 a1 = %s; want M 0000000007", got)
+	}
+}
+
+func TestExecUnknownCommand(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Exec(&out, "frob")
+	if got, want := out.String(), "Unknown command: frob\n"; got != want {
+		t.Errorf("Exec(frob) wrote %q; want %q", got, want)
+	}
+}
+
+func TestRunAdvancesAddCycle(t *testing.T) {
+	m := NewMachine(MachineConn{Output: &bytes.Buffer{}})
+	m.Cycle().SetTestMode()
+	m.Run(10)
+	if m.AddCycle() != 10 {
+		t.Errorf("AddCycle() = %d; want 10", m.AddCycle())
+	}
+}
+
+func TestSaveRestoreCommands(t *testing.T) {
+	var out bytes.Buffer
+	path := filepath.Join(t.TempDir(), "state.json")
+	m1 := NewMachine(MachineConn{Output: &out})
+	m1.Cycle().SetTestMode()
+	m1.Exec(&out, "set a3 123")
+	m1.Run(7)
+	m1.Exec(&out, "save "+path)
+	m2 := NewMachine(MachineConn{Output: &out})
+	m2.Exec(&out, "restore "+path)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	if got := string(m2.Units().Accumulator[2].Value()); got != "P 0000000123" {
+		t.Errorf("a3 = %s; want P 0000000123", got)
+	}
+	if m2.AddCycle() != 7 {
+		t.Errorf("AddCycle() = %d; want 7", m2.AddCycle())
+	}
+}
+
+func TestPowerOn(t *testing.T) {
+	var out bytes.Buffer
+	state := func(mode string, commands ...string) string {
+		m := NewMachine(MachineConn{Output: &out})
+		if err := m.PowerOn(mode); err != nil {
+			t.Fatalf("PowerOn(%s): %s", mode, err)
+		}
+		for _, command := range commands {
+			m.Exec(&out, command)
+		}
+		var dump bytes.Buffer
+		m.DumpAll(&dump)
+		return dump.String()
+	}
+	zero := state("zero")
+	if a, b := state("random:1"), state("random:1"); a != b {
+		t.Errorf("random:1 differs between runs:\n%s\n%s", a, b)
+	}
+	if state("random:1") == zero {
+		t.Errorf("random:1 has the same state as zero")
+	}
+	if state("random:1") == state("random:2") {
+		t.Errorf("random:1 and random:2 have the same state")
+	}
+	if cleared := state("random:1", "b c"); cleared != zero {
+		t.Errorf("b c after random:1 gives\n%s\nwant\n%s", cleared, zero)
+	}
+	m := NewMachine(MachineConn{Output: &out})
+	for _, mode := range []string{"random", "random:x", "one"} {
+		if m.PowerOn(mode) == nil {
+			t.Errorf("PowerOn(%s) succeeded", mode)
+		}
+	}
+	// Program flip-flops start set at random, and b c clears them.
+	busy := func(m *Machine) (accumulators, fts, steppers int) {
+		for _, a := range m.Units().Accumulator {
+			if a.Busy() {
+				accumulators++
+			}
+		}
+		for _, f := range m.Units().Ft {
+			if f.Busy() {
+				fts++
+			}
+		}
+		stat := m.Units().Mp.Stat()
+		steppers = 10 - strings.Count(stat[strings.LastIndexByte(stat, ' '):], "0")
+		return
+	}
+	var accumulators, fts, steppers int
+	for seed := 1; seed <= 20; seed++ {
+		m := NewMachine(MachineConn{Output: &out})
+		m.PowerOn(fmt.Sprintf("random:%d", seed))
+		a, f, s := busy(m)
+		accumulators, fts, steppers = accumulators+a, fts+f, steppers+s
+		// Lookups left from power on end, even with the ring past the repeat
+		// stage.
+		running := NewMachine(MachineConn{Output: &out})
+		running.PowerOn(fmt.Sprintf("random:%d", seed))
+		running.Cycle().SetTestMode()
+		running.Run(20)
+		if _, f, _ := busy(running); f != 0 {
+			t.Errorf("random:%d leaves %d function tables looking up", seed, f)
+		}
+		m.Exec(&out, "b c")
+		if a, f, s := busy(m); a+f+s != 0 {
+			t.Errorf("random:%d leaves %d accumulator, %d function table and %d stepper programs after b c", seed, a, f, s)
+		}
+	}
+	if accumulators == 0 || fts == 0 || steppers == 0 {
+		t.Errorf("20 seeds start %d accumulator, %d function table and %d stepper programs", accumulators, fts, steppers)
+	}
+}
+
+func TestClearButtonStopsPrograms(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	for _, command := range []string{
+		"p i.Io 1-1",
+		"p 1-1 a1.5i",
+		"s a1.op5 A",
+		"s a1.rp5 9",
+		"p 1-1 f1.1i",
+		"s f1.op1 A0",
+		"b i",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Cycle().SetTestMode()
+	m.Run(2)
+	if !m.Units().Accumulator[0].Busy() || !m.Units().Ft[0].Busy() {
+		t.Fatalf("a1 and f1 should be busy")
+	}
+	m.Exec(&out, "b c")
+	if m.Units().Accumulator[0].Busy() {
+		t.Errorf("a1 busy after b c")
+	}
+	if m.Units().Ft[0].Busy() {
+		t.Errorf("f1 busy after b c")
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestUnplug(t *testing.T) {
+	run := func(commands ...string) (string, string) {
+		var out bytes.Buffer
+		m := NewMachine(MachineConn{Output: &out})
+		for _, command := range append([]string{
+			"set a1 12",
+			"p i.Io 1-1",
+			"p 1-1 a1.1i",
+			"s a1.op1 A",
+			"p 1-1 a2.1i",
+			"s a2.op1 α",
+			"p 1-1 a3.1i",
+			"s a3.op1 α",
+			"p 1-1 a4.1i",
+			"s a4.op1 α",
+			"p a1.A 1",
+			"s ad.permute.1 11,10,9,8,7,6,5,4,3,2,1",
+			"p 1 ad.permute.1",
+			"p ad.permute.1 a2.α",
+		}, commands...) {
+			m.Exec(&out, command)
+		}
+		m.Exec(&out, "b i")
+		m.Cycle().SetTestMode()
+		m.Run(3)
+		u := m.Units()
+		return out.String(), fmt.Sprintf("%s %s %s",
+			u.Accumulator[1].Value(), u.Accumulator[2].Value(), u.Accumulator[3].Value())
+	}
+	tests := []struct {
+		commands []string
+		want     string
+	}{
+		{nil, "P 0000000012 P 0000000000 P 0000000000"},
+		{[]string{"u ad.permute.1 a2.α", "p ad.permute.1 a3.α"}, "P 0000000000 P 0000000012 P 0000000000"},
+		{[]string{"u 1 ad.permute.1", "p 1 a4.α"}, "P 0000000000 P 0000000000 P 0000000012"},
+		{[]string{"u 1"}, "P 0000000000 P 0000000000 P 0000000000"},
+		{[]string{"u ad.permute.1", "p ad.permute.1 a4.α"}, "P 0000000000 P 0000000000 P 0000000000"},
+	}
+	for _, test := range tests {
+		out, got := run(test.commands...)
+		if out != "" {
+			t.Errorf("%v: unexpected output %q", test.commands, out)
+		}
+		if got != test.want {
+			t.Errorf("%v: got %s; want %s", test.commands, got, test.want)
+		}
+	}
+}
+
+func TestUnplugErrors(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Exec(&out, "p a1.A 1")
+	m.Exec(&out, "u a1.A 2")
+	m.Exec(&out, "u a1.A")
+	m.Exec(&out, "u")
+	want := "Unplug error: a1.A is not connected to 2\n" +
+		"Invalid unplug spec u\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if lines := m.plugLines(); len(lines) != 0 {
+		t.Errorf("plugs remain after unplug: %v", lines)
+	}
+	m.Exec(&out, "p 1 ad.dp.1.10")
+	m.Exec(&out, "p ad.dp.1.10 2-1")
+	m.Exec(&out, "p ad.dp.1.11 2-2")
+	m.Exec(&out, "u ad.dp.1.10 2-1")
+	if got := m.adapters.dp[0].refMask; got != 1<<10 {
+		t.Errorf("refMask = %x; want %x", got, 1<<10)
+	}
+}
+
+func TestFtSubtractSwitchRange(t *testing.T) {
+	// Only digits 5-10 have subtract switches.
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Exec(&out, "s f1.A4S S")
+	m.Exec(&out, "s f1.B11S S")
+	m.Exec(&out, "s f1.A5S S")
+	want := "error finding switch: invalid switch A4S\n" +
+		"error finding switch: invalid switch B11S\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMpDecadeInputNames(t *testing.T) {
+	// Decade inputs are numbered from the right, as in the p command, so
+	// p.14di is decade 7 from the left.
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Exec(&out, "p 1-1 p.14di")
+	m.Exec(&out, "p? 1-1")
+	if got, want := out.String(), "1-1 p.14di\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestAssertionGet(t *testing.T) {
+	// s? shows an assertion in the form s takes it.
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	m.Exec(&out, "s debug.assert.1 a5~Mxx54xxxxxx")
+	m.Exec(&out, "s? debug.assert.1")
+	if got, want := out.String(), "a5~Mxx54xxxxxx\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestAdapterSwitchGet(t *testing.T) {
+	// s? shows adapter settings in the form s takes them.
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ad.s.1", "-3"},
+		{"ad.permute.1", "0,2,1,0,0,0,0,0,0,0,11"},
+	}
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	for _, test := range tests {
+		out.Reset()
+		m.Exec(&out, "s "+test.name+" "+test.value)
+		m.Exec(&out, "s? "+test.name)
+		if got, want := out.String(), test.value+"\n"; got != want {
+			t.Errorf("%s: got %q; want %q", test.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package eniac
+
+import (
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// tracedNet is a routing jack, e.g. a tray or pulse amplifier, whose pulses
+// are logged to tracer as name.  Routing jacks have no handlers of their own
+// since pulses skip straight to their final receivers, so the outputs which
+// drive them are tapped instead.  Where several outputs drive a net in the
+// same time step, the tracer ORs their pulses together.
+type tracedNet struct {
+	tracer Tracer
+	name   string
+	bits   int
+}
+
+// tracedNets returns the nets being traced.
+func (m *Machine) tracedNets() map[*Jack]tracedNet {
+	nets := make(map[*Jack]tracedNet)
+	m.trays.tracedNets(nets)
+	m.pulseAmps.tracedNets(nets)
+	return nets
+}
+
+// addNetTraceTaps taps every plugged output which drives a traced net to log
+// the pulses it sends there.
+func (m *Machine) addNetTraceTaps() {
+	nets := m.tracedNets()
+	if len(nets) == 0 && m.adapters.tracer == nil {
+		return
+	}
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) || len(j.Receivers) == 0 {
+			continue
+		}
+		reached := netsFrom(j, nets)
+		// Permute adapters with a single receiver send to it directly rather
+		// than from their output, so log what they would have sent when their
+		// input is reached.
+		type permuterNets struct {
+			p    *permuter
+			nets []tracedNet
+		}
+		var permuters []permuterNets
+		for _, r := range j.FinalReceivers() {
+			p := m.hardwiredPermuter(r)
+			if p == nil {
+				continue
+			}
+			x := permuterNets{p, netsFrom(p.out, nets)}
+			if m.adapters.tracer != nil {
+				x.nets = append(x.nets, tracedNet{m.adapters.tracer, p.out.Name, 11})
+			}
+			permuters = append(permuters, x)
+		}
+		if len(reached) == 0 && len(permuters) == 0 {
+			continue
+		}
+		m.addTap(j, func(_ *Jack, val int) {
+			logNets(reached, val)
+			for _, x := range permuters {
+				if v := permuted(x.p, val); v != 0 && !x.p.in.Disabled {
+					logNets(x.nets, v)
+				}
+			}
+		})
+	}
+}
+
+// netsFrom returns the traced nets that pulses sent by j travel over.
+func netsFrom(j *Jack, nets map[*Jack]tracedNet) []tracedNet {
+	var reached []tracedNet
+	visited := make(map[*Jack]bool)
+	var visit func(*Jack)
+	visit = func(j *Jack) {
+		for _, r := range j.Receivers {
+			if visited[r] || !isRoutingJack(r) {
+				continue
+			}
+			visited[r] = true
+			if n, ok := nets[r]; ok {
+				reached = append(reached, n)
+			}
+			visit(r)
+		}
+	}
+	visit(j)
+	return reached
+}
+
+func logNets(nets []tracedNet, val int) {
+	for _, n := range nets {
+		n.tracer.LogPulse(n.name, n.bits, int64(val))
+	}
+}
@@ -0,0 +1,176 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraceAllUnits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nettrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd")
+
+	var out bytes.Buffer
+	m := newCounter(&out)
+	// Send a1's output to a2 through a pulse amplifier.
+	m.Exec(&out, "u a1.A")
+	m.Exec(&out, "p a1.A pa.1.sa")
+	m.Exec(&out, "p pa.1.sb 1")
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "ts pf")
+	m.Run(12)
+	m.Exec(&out, "te "+filename)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	if got := string(m.Units().Accumulator[1].Value()); got != "P 0000000009" {
+		t.Errorf("a2 = %s; want P 0000000009", got)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"pa.1.sb[10:0]", "p.A[2:0]", "p.d20[3:0]", "f1.argument[6:0]", "f3.ring[3:0]",
+		"i.rdff", "i.Io", "os.ring[2:0]", "st.stage[3:0]", "sjk2.stage[3:0]",
+	} {
+		if !strings.Contains(string(data), " "+name+" ") {
+			t.Errorf("missing %s", name)
+		}
+	}
+}
+
+func TestTraceNets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nettrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd")
+
+	tests := []struct {
+		adapter string
+		setting string
+		a2      string
+	}{
+		{"s", "s ad.s.1 1", "P 0000000090"},
+		{"permute", "s ad.permute.1 0,2,1,0,0,0,0,0,0,0,0", "P 0900000000"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		m := newCounter(&out)
+		m.Exec(&out, "u a1.A")
+		m.Exec(&out, "p a1.A ad."+test.adapter+".1")
+		m.Exec(&out, "p ad."+test.adapter+".1 1")
+		m.Exec(&out, test.setting)
+		if test.adapter == "permute" && m.hardwiredPermuter(m.adapters.permute[0].in) == nil {
+			t.Fatalf("permuter not hardwired")
+		}
+		m.Cycle().SetTestMode()
+		m.Exec(&out, "ts p")
+		m.Run(12)
+		m.Exec(&out, "te "+filename)
+		if out.Len() != 0 {
+			t.Fatalf("%s: unexpected output %q", test.adapter, out.String())
+		}
+		if got := string(m.Units().Accumulator[1].Value()); got != test.a2 {
+			t.Errorf("%s: a2 = %s; want %s", test.adapter, got, test.a2)
+		}
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes := vcdChanges(string(data))
+		adapterOut := changes["ad."+test.adapter+".o.1[10:0]"]
+		if len(adapterOut) == 0 {
+			t.Errorf("%s: no adapter output traced", test.adapter)
+		}
+		if trunk := changes["trunk.1[10:0]"]; trunk != adapterOut {
+			t.Errorf("%s: trunk.1 %s; want %s", test.adapter, trunk, adapterOut)
+		}
+		if len(changes["tray.A-1"]) == 0 {
+			t.Errorf("%s: no program pulses on A-1", test.adapter)
+		}
+	}
+}
+
+// vcdChanges returns the times and values each signal in vcd takes.
+func vcdChanges(vcd string) map[string]string {
+	names := make(map[string]string)
+	changes := make(map[string]string)
+	time := "" // Initial values in $dumpvars are not changes
+	for _, line := range strings.Split(vcd, "\n") {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 6 && f[0] == "$var":
+			names[f[3]] = f[4]
+		case time == "" && !strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "#"):
+			time = line
+		case len(f) == 2 && names[f[1]] != "":
+			changes[names[f[1]]] += time + ":" + f[0] + " "
+		case len(f) == 1 && len(line) >= 2 && names[line[1:]] != "":
+			changes[names[line[1:]]] += time + ":" + line[:1] + " "
+		}
+	}
+	return changes
+}
+
+func TestTraceNetDrivers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nettrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd")
+
+	// Both in memory and streamed, the pulses a3 and a4 send together through
+	// pulse amplifier 1 onto trunk 2, and onto program line 2-1, are combined.
+	for _, commands := range [][]string{
+		{"ts p", "te " + filename},
+		{"ts p " + filename, "te"},
+	} {
+		var out bytes.Buffer
+		m := newMachineWith(&out,
+			"set a3 1", "set a4 10",
+			"p i.Io 1-1",
+			"p 1-1 a3.5i", "s a3.op5 A", "s a3.rp5 1",
+			"p 1-1 a4.5i", "s a4.op5 A", "s a4.rp5 1",
+			"p a3.A pa.1.sa", "p a4.A pa.1.sa", "p pa.1.sb 2",
+			"p 2 a5.α", "p 1-1 a5.5i", "s a5.op5 α", "s a5.rp5 1",
+			"p a3.5o 2-1", "p a4.5o 2-1", "p 2-1 a6.5i",
+			"b i",
+		)
+		m.Cycle().SetTestMode()
+		m.Exec(&out, commands[0])
+		m.Run(4)
+		m.Exec(&out, commands[1])
+		if out.Len() != 0 {
+			t.Fatalf("%s: unexpected output %q", commands[0], out.String())
+		}
+		if got := string(m.Units().Accumulator[4].Value()); got != "P 0000000011" {
+			t.Errorf("%s: a5 = %s; want P 0000000011", commands[0], got)
+		}
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes := vcdChanges(string(data))
+		if got, want := changes["trunk.2[10:0]"], "#50:b00000000011 #51:b00000000000 "; got != want {
+			t.Errorf("%s: trunk.2 %s; want %s", commands[0], got, want)
+		}
+		if got, want := changes["pa.1.sb[10:0]"], "#50:b00000000011 #51:b00000000000 "; got != want {
+			t.Errorf("%s: pa.1.sb %s; want %s", commands[0], got, want)
+		}
+		if got, want := changes["tray.B-1"], "#58:1 #59:0 "; got != want {
+			t.Errorf("%s: tray.B-1 %s; want %s", commands[0], got, want)
+		}
+	}
+}
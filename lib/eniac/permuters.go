@@ -1,5 +1,5 @@
 // Generated by gen_permuters.go
-package main
+package eniac
 
 import (
 	. "github.com/jeredw/eniacsim/lib"
@@ -0,0 +1,84 @@
+package eniac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jeredw/eniacsim/lib/units"
+)
+
+// probe returns a function reading the unit state named by name, for
+// debug.assert and debug.dump.  Values are in the form accumulators are
+// displayed in, e.g. "M 9876543210", or plain numbers for steppers and
+// counters.  Names are
+//
+//	aN              accumulator N
+//	p.X             master programmer stepper X stage (1-6)
+//	p.dN            master programmer decade counter N
+//	fN.arg          function table N argument
+//	fN.A, fN.B      function table N value looked up for the argument
+//	c               constant selected for transmission
+//	m.ier, m.icand  multiplier and multiplicand
+//	st, sft, pm1, pm2, sjk1, sjk2
+//	                auxiliary stepper stage
+func (m *Machine) probe(name string) (func() string, error) {
+	u := m.u
+	switch {
+	case len(name) > 1 && name[0] == 'a':
+		n, err := strconv.Atoi(name[1:])
+		if err != nil || !(n >= 1 && n <= 20) {
+			return nil, fmt.Errorf("invalid accumulator %s", name)
+		}
+		a := u.Accumulator[n-1]
+		return func() string { return string(a.Value()) }, nil
+	case len(name) == 3 && strings.HasPrefix(name, "p.") && u.Mp.Stage(name[2]) != 0:
+		stepper := name[2]
+		return func() string { return strconv.Itoa(u.Mp.Stage(stepper)) }, nil
+	case strings.HasPrefix(name, "p.d"):
+		n, _ := strconv.Atoi(name[3:])
+		if u.Mp.Decade(n) < 0 {
+			return nil, fmt.Errorf("invalid decade counter %s", name)
+		}
+		return func() string { return strconv.Itoa(u.Mp.Decade(n)) }, nil
+	case len(name) > 3 && name[0] == 'f' && name[2] == '.':
+		n := int(name[1] - '0')
+		if !(n >= 1 && n <= 3) {
+			return nil, fmt.Errorf("invalid function table %s", name)
+		}
+		ft := u.Ft[n-1]
+		switch name[3:] {
+		case "arg":
+			return func() string { return fmt.Sprintf("%02d", ft.Arg()) }, nil
+		case "A", "B":
+			side := name[3]
+			return func() string { return ft.Output(side) }, nil
+		}
+		return nil, fmt.Errorf("invalid function table register %s", name)
+	case name == "c":
+		return u.Constant.Value, nil
+	case name == "m.ier":
+		return u.Multiplier.Ier, nil
+	case name == "m.icand":
+		return u.Multiplier.Icand, nil
+	}
+	if s := m.auxStepper(name); s != nil {
+		return func() string { return strconv.Itoa(s.Stage()) }, nil
+	}
+	return nil, fmt.Errorf("invalid probe %s", name)
+}
+
+func (m *Machine) auxStepper(name string) *units.AuxStepper {
+	u := m.u
+	switch name {
+	case "st":
+		return u.TenStepper
+	case "sft":
+		return u.FtSelector
+	case "pm1", "pm2":
+		return u.PmDiscriminator[name[2]-'1']
+	case "sjk1", "sjk2":
+		return u.JkSelector[name[3]-'1']
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package eniac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// profile counts where machine time goes during a run: how often each
+// program tray line fires, how many add cycles each unit spends busy and each
+// master programmer stepper spends in each stage, and how long programs wait
+// for the card reader and printer.
+type profile struct {
+	AddCycles   int64               `json:"addCycles"`
+	ReaderWait  int64               `json:"readerWait"`
+	PrinterWait int64               `json:"printerWait"`
+	Busy        map[string]int64    `json:"busy"`
+	Stages      map[string][6]int64 `json:"stages"`
+	Lines       map[string]int64    `json:"lines"`
+
+	running bool
+}
+
+func newProfile() *profile {
+	return &profile{
+		Busy:    make(map[string]int64),
+		Stages:  make(map[string][6]int64),
+		Lines:   make(map[string]int64),
+		running: true,
+	}
+}
+
+// StartProfile discards any previous profile and starts counting.
+func (m *Machine) StartProfile() {
+	m.profile = newProfile()
+	m.updateTaps()
+	m.updateAddCycleHook()
+}
+
+// StopProfile stops counting, keeping the profile for reports.
+func (m *Machine) StopProfile() {
+	if m.profile != nil {
+		m.profile.running = false
+		m.updateTaps()
+		m.updateAddCycleHook()
+	}
+}
+
+// addProfileTaps counts pulses on program trays.
+func (m *Machine) addProfileTaps() {
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) || len(j.Receivers) == 0 {
+			continue
+		}
+		var lines []string
+		for _, t := range trunksFrom(j) {
+			if strings.Contains(t, "-") {
+				lines = append(lines, t)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		m.addTap(j, func(j *Jack, val int) {
+			if val != 0 && m.profile != nil {
+				for _, t := range lines {
+					m.profile.Lines[t]++
+				}
+			}
+		})
+	}
+}
+
+// sampleProfile is called at the end of each add cycle while profiling.
+func (m *Machine) sampleProfile() {
+	p := m.profile
+	p.AddCycles++
+	u := m.u
+	if u.Initiate.WaitingForReader() {
+		p.ReaderWait++
+	}
+	if u.Initiate.WaitingForPrinter() {
+		p.PrinterWait++
+	}
+	for i, a := range u.Accumulator {
+		if a.Busy() {
+			p.Busy[fmt.Sprintf("a%d", i+1)]++
+		}
+	}
+	for i, f := range u.Ft {
+		if f.Busy() {
+			p.Busy[fmt.Sprintf("f%d", i+1)]++
+		}
+	}
+	if u.Multiplier.Busy() {
+		p.Busy["m"]++
+	}
+	if u.Divsr.Busy() {
+		p.Busy["d"]++
+	}
+	for _, s := range []byte("ABCDEFGHJK") {
+		name := "p." + string(s)
+		stages := p.Stages[name]
+		stages[u.Mp.Stage(s)-1]++
+		p.Stages[name] = stages
+	}
+}
+
+// WriteReport writes a summary of p to w, busiest first.
+func (p *profile) WriteReport(w io.Writer) {
+	percent := func(n int64) float64 {
+		if p.AddCycles == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(p.AddCycles)
+	}
+	fmt.Fprintf(w, "add cycles %d\n", p.AddCycles)
+	fmt.Fprintf(w, "reader wait %d (%.1f%%)\n", p.ReaderWait, percent(p.ReaderWait))
+	fmt.Fprintf(w, "printer wait %d (%.1f%%)\n", p.PrinterWait, percent(p.PrinterWait))
+	fmt.Fprintln(w, "busy add cycles:")
+	for _, name := range sortedByCount(p.Busy) {
+		fmt.Fprintf(w, "  %-4s %d (%.1f%%)\n", name, p.Busy[name], percent(p.Busy[name]))
+	}
+	fmt.Fprintln(w, "stepper stages:")
+	var steppers []string
+	for name, stages := range p.Stages {
+		// Skip steppers which never left their first stage.
+		if stages[0] != p.AddCycles {
+			steppers = append(steppers, name)
+		}
+	}
+	sort.Strings(steppers)
+	for _, name := range steppers {
+		fmt.Fprintf(w, "  %s", name)
+		for i, n := range p.Stages[name] {
+			fmt.Fprintf(w, " %d:%d", i+1, n)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "program line pulses:")
+	for _, name := range sortedByCount(p.Lines) {
+		fmt.Fprintf(w, "  %-5s %d\n", name, p.Lines[name])
+	}
+}
+
+// sortedByCount returns the keys of counts with nonzero counts, largest
+// first.
+func sortedByCount(counts map[string]int64) []string {
+	var keys []string
+	for k, n := range counts {
+		if n != 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (m *Machine) doProfile(w io.Writer, f []string) {
+	switch {
+	case len(f) == 2 && f[1] == "start":
+		m.StartProfile()
+	case len(f) == 2 && f[1] == "stop":
+		m.StopProfile()
+	case len(f) == 1:
+		if m.profile == nil {
+			fmt.Fprintln(w, "not profiling; missing profile start?")
+			return
+		}
+		m.profile.WriteReport(w)
+	case len(f) == 3 && f[1] == "json":
+		if m.profile == nil {
+			fmt.Fprintln(w, "not profiling; missing profile start?")
+			return
+		}
+		data, err := json.MarshalIndent(m.profile, "", "  ")
+		if err != nil {
+			fmt.Fprintf(w, "profile: %s\n", err)
+			return
+		}
+		if err := ioutil.WriteFile(f[2], data, 0666); err != nil {
+			fmt.Fprintf(w, "profile: %s\n", err)
+		}
+	default:
+		fmt.Fprintln(w, "profile syntax: profile [start|stop|json file]")
+	}
+}
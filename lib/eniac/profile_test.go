@@ -0,0 +1,56 @@
+package eniac
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Exec(&out, "profile start")
+	m.Cycle().SetTestMode()
+	m.Run(12)
+	m.Exec(&out, "profile stop")
+	m.Run(5)
+	m.Exec(&out, "profile")
+	want := "add cycles 12\n" +
+		"reader wait 0 (0.0%)\n" +
+		"printer wait 0 (0.0%)\n" +
+		"busy add cycles:\n" +
+		"  a1   9 (75.0%)\n" +
+		"  a2   9 (75.0%)\n" +
+		"stepper stages:\n" +
+		"program line pulses:\n" +
+		"  1-1   1\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "profile.json")
+	out.Reset()
+	m.Exec(&out, "profile json "+filename)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.AddCycles != 12 || p.Busy["a2"] != 9 || p.Lines["1-1"] != 1 || p.Stages["p.A"][0] != 12 {
+		t.Errorf("unexpected profile %s", data)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package eniac
 
 import (
 	"fmt"
@@ -24,6 +24,8 @@ type PulseAmps struct {
 	digitOutput [8]*Jack
 	progInput   [8][11]*Jack
 	progOutput  [8][11]*Jack
+
+	tracer Tracer
 }
 
 func NewPulseAmps() *PulseAmps {
@@ -45,6 +47,27 @@ func NewPulseAmps() *PulseAmps {
 	return pa
 }
 
+// AttachTracer connects a trace logger, which sees the pulses each pulse
+// amplifier passes on its outputs, pa.N.sb for digits or pa.N.sb.M for
+// program lines.
+func (pa *PulseAmps) AttachTracer(tracer Tracer) {
+	pa.tracer = tracer
+}
+
+// tracedNets adds the pulse amplifier outputs to nets if tracing.
+func (pa *PulseAmps) tracedNets(nets map[*Jack]tracedNet) {
+	if pa.tracer == nil {
+		return
+	}
+	for unit := range pa.digitOutput {
+		out := pa.digitOutput[unit]
+		nets[out] = tracedNet{pa.tracer, out.Name, 11}
+		for _, out := range pa.progOutput[unit] {
+			nets[out] = tracedNet{pa.tracer, out.Name, 1}
+		}
+	}
+}
+
 func (pa *PulseAmps) FindJack(name string) (*Jack, error) {
 	// (pa.)%d.s[ab]{.%d}
 	p := strings.Split(name, ".")
@@ -0,0 +1,140 @@
+package eniac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// machineSnapshot holds the dynamic state of every clocked unit.  Switch
+// settings and cabling are not included; a snapshot should be restored into a
+// machine configured with the same program.
+type machineSnapshot struct {
+	Cycle           json.RawMessage     `json:"cycle"`
+	Initiate        json.RawMessage     `json:"initiate"`
+	Mp              json.RawMessage     `json:"mp"`
+	Divsr           json.RawMessage     `json:"divsr"`
+	Multiplier      json.RawMessage     `json:"multiplier"`
+	Constant        json.RawMessage     `json:"constant"`
+	Ft              [3]json.RawMessage  `json:"ft"`
+	Accumulator     [20]json.RawMessage `json:"accumulator"`
+	TenStepper      json.RawMessage     `json:"tenStepper"`
+	FtSelector      json.RawMessage     `json:"ftSelector"`
+	PmDiscriminator [2]json.RawMessage  `json:"pmDiscriminator"`
+	JkSelector      [2]json.RawMessage  `json:"jkSelector"`
+	OrderSelector   json.RawMessage     `json:"orderSelector"`
+}
+
+type snapshotPart struct {
+	name string
+	unit interface {
+		Restore(json.RawMessage) error
+	}
+	data json.RawMessage
+}
+
+// Snapshot returns the dynamic state of the machine, encoded as JSON.
+func (m *Machine) Snapshot() []byte {
+	u := m.u
+	s := machineSnapshot{
+		Cycle:         m.cycle.Snapshot(),
+		Initiate:      u.Initiate.Snapshot(),
+		Mp:            u.Mp.Snapshot(),
+		Divsr:         u.Divsr.Snapshot(),
+		Multiplier:    u.Multiplier.Snapshot(),
+		Constant:      u.Constant.Snapshot(),
+		TenStepper:    u.TenStepper.Snapshot(),
+		FtSelector:    u.FtSelector.Snapshot(),
+		OrderSelector: u.OrderSelector.Snapshot(),
+	}
+	for i := range u.Ft {
+		s.Ft[i] = u.Ft[i].Snapshot()
+	}
+	for i := range u.Accumulator {
+		s.Accumulator[i] = u.Accumulator[i].Snapshot()
+	}
+	for i := range u.PmDiscriminator {
+		s.PmDiscriminator[i] = u.PmDiscriminator[i].Snapshot()
+	}
+	for i := range u.JkSelector {
+		s.JkSelector[i] = u.JkSelector[i].Snapshot()
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads machine state saved by Snapshot.  Checkpoints kept for
+// Back are dropped.
+func (m *Machine) Restore(data []byte) error {
+	if err := m.restoreState(data); err != nil {
+		return err
+	}
+	m.resetHistory()
+	return nil
+}
+
+func (m *Machine) restoreState(data []byte) error {
+	s := machineSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u := m.u
+	parts := []snapshotPart{
+		{"cycle", m.cycle, s.Cycle},
+		{"initiate", u.Initiate, s.Initiate},
+		{"mp", u.Mp, s.Mp},
+		{"divsr", u.Divsr, s.Divsr},
+		{"multiplier", u.Multiplier, s.Multiplier},
+		{"constant", u.Constant, s.Constant},
+		{"st", u.TenStepper, s.TenStepper},
+		{"sft", u.FtSelector, s.FtSelector},
+		{"os", u.OrderSelector, s.OrderSelector},
+	}
+	for i := range u.Ft {
+		parts = append(parts, snapshotPart{fmt.Sprintf("f%d", i+1), u.Ft[i], s.Ft[i]})
+	}
+	for i := range u.Accumulator {
+		parts = append(parts, snapshotPart{fmt.Sprintf("a%d", i+1), u.Accumulator[i], s.Accumulator[i]})
+	}
+	for i := range u.PmDiscriminator {
+		parts = append(parts, snapshotPart{fmt.Sprintf("pm%d", i+1), u.PmDiscriminator[i], s.PmDiscriminator[i]})
+	}
+	for i := range u.JkSelector {
+		parts = append(parts, snapshotPart{fmt.Sprintf("sjk%d", i+1), u.JkSelector[i], s.JkSelector[i]})
+	}
+	for _, p := range parts {
+		if p.data == nil {
+			return fmt.Errorf("snapshot missing %s", p.name)
+		}
+		if err := p.unit.Restore(p.data); err != nil {
+			return fmt.Errorf("%s: %s", p.name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Machine) doSave(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "save syntax: save file")
+		return
+	}
+	if err := ioutil.WriteFile(f[1], m.Snapshot(), 0644); err != nil {
+		fmt.Fprintf(w, "save: %s\n", err)
+	}
+}
+
+func (m *Machine) doRestore(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "restore syntax: restore file")
+		return
+	}
+	data, err := ioutil.ReadFile(f[1])
+	if err != nil {
+		fmt.Fprintf(w, "restore: %s\n", err)
+		return
+	}
+	if err := m.Restore(data); err != nil {
+		fmt.Fprintf(w, "restore: %s\n", err)
+	}
+}
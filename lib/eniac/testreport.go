@@ -0,0 +1,118 @@
+package eniac
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// AssertionResult is the outcome of one debug.assert over a run.
+type AssertionResult struct {
+	Name     string             `json:"name"`
+	Target   string             `json:"target"`
+	Expected string             `json:"expected"`
+	Checks   int                `json:"checks"`
+	Failures []AssertionFailure `json:"failures"`
+}
+
+// AssertionFailure is one time an assertion didn't match.
+type AssertionFailure struct {
+	Cycle  int64  `json:"cycle"`
+	Actual string `json:"actual"`
+}
+
+// TestReport summarizes the assertions checked in a run.
+type TestReport struct {
+	Name       string            `json:"name"`
+	Cycles     int64             `json:"cycles"`
+	Failed     int               `json:"failed"`
+	Assertions []AssertionResult `json:"assertions"`
+}
+
+// TestReport returns the results of every configured debug.assert, sorted by
+// name.  name identifies the run, e.g. the configuration file.
+func (m *Machine) TestReport(name string) *TestReport {
+	r := &TestReport{Name: name, Cycles: m.cycle.AddCycle, Assertions: []AssertionResult{}}
+	for id, a := range m.debugger.assert {
+		if a.value == nil {
+			continue
+		}
+		result := AssertionResult{
+			Name:     "debug.assert." + id,
+			Target:   a.target,
+			Expected: a.expectedDigits,
+			Checks:   a.checks,
+			Failures: []AssertionFailure{},
+		}
+		for _, f := range a.failures {
+			result.Failures = append(result.Failures, AssertionFailure{f.cycle, f.actual})
+		}
+		if len(a.failures) != 0 {
+			r.Failed++
+		}
+		r.Assertions = append(r.Assertions, result)
+	}
+	sort.Slice(r.Assertions, func(i, j int) bool {
+		return r.Assertions[i].Name < r.Assertions[j].Name
+	})
+	return r
+}
+
+// WriteJSON writes r as JSON.
+func (r *TestReport) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes r as JUnit XML, with one test case per assertion.
+func (r *TestReport) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{Name: r.Name, Tests: len(r.Assertions), Failures: r.Failed}
+	for _, a := range r.Assertions {
+		tc := junitTestCase{Name: a.Name, ClassName: r.Name}
+		if len(a.Failures) != 0 {
+			f := a.Failures[0]
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%s = %s !~ %s at add cycle %d", a.Target, f.Actual, a.Expected, f.Cycle),
+			}
+			for _, f := range a.Failures {
+				tc.Failure.Text += fmt.Sprintf("add cycle %d: %s = %s !~ %s\n", f.Cycle, a.Target, f.Actual, a.Expected)
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
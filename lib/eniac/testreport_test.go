@@ -0,0 +1,60 @@
+package eniac
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTestReport(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	for _, command := range []string{
+		"p a2.5o 1-2",
+		"p 1-2 debug.assert.count",
+		"s debug.assert.count a2~P0000000008",
+		"p 1-2 debug.assert.ok",
+		"s debug.assert.ok a1~P0000000001",
+		"p 1-2 debug.assert.unset",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Cycle().SetTestMode()
+	m.Run(20)
+	r := m.TestReport("counter.e")
+	if r.Failed != 1 || len(r.Assertions) != 2 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	count := r.Assertions[0]
+	if count.Name != "debug.assert.count" || count.Checks != 1 || len(count.Failures) != 1 {
+		t.Fatalf("unexpected result %+v", count)
+	}
+	if f := count.Failures[0]; f.Cycle != 10 || f.Actual != "P 0000000009" {
+		t.Errorf("unexpected failure %+v", f)
+	}
+	if ok := r.Assertions[1]; ok.Name != "debug.assert.ok" || ok.Checks != 1 || len(ok.Failures) != 0 {
+		t.Errorf("unexpected result %+v", ok)
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuite name="counter.e" tests="2" failures="1">`,
+		`<failure message="a2 = P 0000000009 !~ P0000000008 at add cycle 10">`,
+		`<testcase name="debug.assert.ok" classname="counter.e"></testcase>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("JUnit report missing %s\n%s", want, b.String())
+		}
+	}
+
+	b.Reset()
+	if err := r.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"failed": 1`) {
+		t.Errorf("unexpected JSON report\n%s", b.String())
+	}
+}
@@ -0,0 +1,197 @@
+package eniac
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// microsPerAddCycle is the duration of an add cycle at the normal 100kHz
+// pulse rate.
+const microsPerAddCycle = 200
+
+// timeline records what each unit is doing add cycle by add cycle for ts t,
+// to write as Chrome trace events viewable in Perfetto or chrome://tracing.
+// Each unit is a track: accumulator, function table, multiplier and divider
+// programs are slices, master programmer steppers are counters and card reads
+// and prints are instant events.
+type timeline struct {
+	events []traceEvent
+	open   map[timelineSlice]int64 // Start add cycle of slices in progress
+	stages map[byte]int            // Last stage of each stepper
+}
+
+type timelineSlice struct {
+	tid     int
+	program int
+}
+
+type traceEvent struct {
+	Name  string                 `json:"name"`
+	Phase string                 `json:"ph"`
+	Time  int64                  `json:"ts"`
+	Dur   int64                  `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// Track ids.  Accumulators are 1-20.
+const (
+	tidFt         = 21 // 21-23
+	tidMultiplier = 24
+	tidDivsr      = 25
+	tidInitiate   = 26
+)
+
+func newTimeline() *timeline {
+	return &timeline{
+		open:   make(map[timelineSlice]int64),
+		stages: make(map[byte]int),
+	}
+}
+
+// sampleTimeline is called at the end of each add cycle while recording a
+// timeline.  Programs seen in progress run during the next add cycle.
+func (m *Machine) sampleTimeline() {
+	t := m.timeline
+	now := m.cycle.AddCycle
+	u := m.u
+	for i, a := range u.Accumulator {
+		active := a.ActivePrograms()
+		for p := 1; p <= 12; p++ {
+			t.setActive(now, i+1, p, active&(1<<uint(p-1)) != 0)
+		}
+	}
+	for i, f := range u.Ft {
+		t.setProgram(now, tidFt+i, 11, f.Program())
+	}
+	t.setProgram(now, tidMultiplier, 24, u.Multiplier.Program())
+	t.setProgram(now, tidDivsr, 8, u.Divsr.Program())
+	for _, s := range []byte("ABCDEFGHJK") {
+		stage := u.Mp.Stage(s)
+		if last, ok := t.stages[s]; !ok || last != stage {
+			t.stages[s] = stage
+			t.events = append(t.events, traceEvent{
+				Name:  "p." + string(s),
+				Phase: "C",
+				Time:  now * microsPerAddCycle,
+				Pid:   1,
+				Args:  map[string]interface{}{"stage": stage},
+			})
+		}
+	}
+}
+
+// setProgram marks program active on track tid and the others up to n
+// inactive.
+func (t *timeline) setProgram(now int64, tid, n, active int) {
+	for p := 1; p <= n; p++ {
+		t.setActive(now, tid, p, p == active)
+	}
+}
+
+// setActive starts or ends a slice for program on track tid.
+func (t *timeline) setActive(now int64, tid, program int, active bool) {
+	key := timelineSlice{tid, program}
+	start, open := t.open[key]
+	switch {
+	case active && !open:
+		t.open[key] = now
+	case !active && open:
+		delete(t.open, key)
+		t.events = append(t.events, traceEvent{
+			Name:  fmt.Sprintf("program %d", program),
+			Phase: "X",
+			Time:  start * microsPerAddCycle,
+			Dur:   (now - start) * microsPerAddCycle,
+			Pid:   1,
+			Tid:   tid,
+		})
+	}
+}
+
+// logCard records a card read or printed.
+func (m *Machine) logCard(name, card string) {
+	if m.timeline == nil || m.replaying {
+		return
+	}
+	m.timeline.events = append(m.timeline.events, traceEvent{
+		Name:  name,
+		Phase: "i",
+		Time:  m.cycle.AddCycle * microsPerAddCycle,
+		Pid:   1,
+		Tid:   tidInitiate,
+		Scope: "t",
+		Args:  map[string]interface{}{"card": card},
+	})
+}
+
+// WriteTimeline writes the timeline in Chrome trace event JSON format.  Slices
+// still in progress end at add cycle now.
+func (t *timeline) WriteTimeline(w io.Writer, now int64) error {
+	events := []traceEvent{{
+		Name: "process_name", Phase: "M", Pid: 1,
+		Args: map[string]interface{}{"name": "ENIAC"},
+	}}
+	threadName := func(tid int, name string) {
+		events = append(events, traceEvent{
+			Name: "thread_name", Phase: "M", Pid: 1, Tid: tid,
+			Args: map[string]interface{}{"name": name},
+		})
+		events = append(events, traceEvent{
+			Name: "thread_sort_index", Phase: "M", Pid: 1, Tid: tid,
+			Args: map[string]interface{}{"sort_index": tid},
+		})
+	}
+	for i := 1; i <= 20; i++ {
+		threadName(i, fmt.Sprintf("a%d", i))
+	}
+	for i := 0; i < 3; i++ {
+		threadName(tidFt+i, fmt.Sprintf("f%d", i+1))
+	}
+	threadName(tidMultiplier, "m")
+	threadName(tidDivsr, "d")
+	threadName(tidInitiate, "i")
+	events = append(events, t.events...)
+	var open []timelineSlice
+	for key := range t.open {
+		open = append(open, key)
+	}
+	sort.Slice(open, func(i, j int) bool {
+		if open[i].tid != open[j].tid {
+			return open[i].tid < open[j].tid
+		}
+		return open[i].program < open[j].program
+	})
+	for _, key := range open {
+		start := t.open[key]
+		events = append(events, traceEvent{
+			Name:  fmt.Sprintf("program %d", key.program),
+			Phase: "X",
+			Time:  start * microsPerAddCycle,
+			Dur:   (now - start) * microsPerAddCycle,
+			Pid:   1,
+			Tid:   key.tid,
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `{"displayTimeUnit": "ms", "traceEvents": [`)
+	for i, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+		if i != len(events)-1 {
+			bw.WriteByte(',')
+		}
+		bw.WriteByte('\n')
+	}
+	fmt.Fprintln(bw, "]}")
+	return bw.Flush()
+}
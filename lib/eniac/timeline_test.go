@@ -0,0 +1,65 @@
+package eniac
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.json")
+
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "ts t")
+	m.Run(12)
+	m.Exec(&out, "te "+filename)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("%s\n%s", err, data)
+	}
+	var slices []traceEvent
+	counters := 0
+	for _, e := range trace.TraceEvents {
+		switch e.Phase {
+		case "X":
+			slices = append(slices, e)
+		case "C":
+			counters++
+		}
+	}
+	// a1 and a2 both run program 5 nine times from add cycle 2.
+	want := []traceEvent{
+		{Name: "program 5", Phase: "X", Time: 400, Dur: 1800, Pid: 1, Tid: 1},
+		{Name: "program 5", Phase: "X", Time: 400, Dur: 1800, Pid: 1, Tid: 2},
+	}
+	if len(slices) != len(want) {
+		t.Fatalf("slices = %+v; want %+v", slices, want)
+	}
+	for i := range want {
+		if s := slices[i]; s.Name != want[i].Name || s.Time != want[i].Time || s.Dur != want[i].Dur || s.Tid != want[i].Tid {
+			t.Errorf("slice %d = %+v; want %+v", i, s, want[i])
+		}
+	}
+	if counters != 10 {
+		t.Errorf("got %d stepper counter events; want 10", counters)
+	}
+}
@@ -0,0 +1,135 @@
+package eniac
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// traceFilter is a Tracer which passes on only the signals matching any of
+// a set of globs, and only while the machine is inside a window of add
+// cycles and between pulses on optional start and stop jacks.  It lets ts
+// record a few units around an interesting moment of a long run.
+type traceFilter struct {
+	Tracer
+
+	globs    []string        // Signal names to keep, e.g. a1.*; all if empty
+	selected map[string]bool // Cached glob matches
+	from, to int64           // Add cycles [from, to) to keep; to 0 for no end
+	addCycle func() int64
+
+	start, stop *Jack // Pulses which start and stop tracing, or nil
+	triggered   bool  // Between start and stop pulses
+}
+
+// parseTraceFilter parses the globs and options given to ts after the trace
+// flags.
+func (m *Machine) parseTraceFilter(args []string) (*traceFilter, error) {
+	t := &traceFilter{
+		selected: make(map[string]bool),
+		addCycle: func() int64 { return m.cycle.AddCycle },
+	}
+	for _, arg := range args {
+		eq := strings.IndexByte(arg, '=')
+		if eq == -1 {
+			if _, err := path.Match(arg, ""); err != nil {
+				return nil, fmt.Errorf("bad pattern %s", arg)
+			}
+			t.globs = append(t.globs, arg)
+			continue
+		}
+		key, value := arg[:eq], arg[eq+1:]
+		switch key {
+		case "from", "to":
+			cycle, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || cycle < 0 {
+				return nil, fmt.Errorf("invalid add cycle %s", value)
+			}
+			if key == "from" {
+				t.from = cycle
+			} else {
+				t.to = cycle
+			}
+		case "start", "stop":
+			jack, _, err := m.findJack(value, 0)
+			if err != nil {
+				return nil, err
+			}
+			if key == "start" {
+				t.start = jack
+			} else {
+				t.stop = jack
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	}
+	if t.to != 0 && t.to <= t.from {
+		return nil, fmt.Errorf("empty window from=%d to=%d", t.from, t.to)
+	}
+	t.triggered = t.start == nil
+	return t, nil
+}
+
+// addTraceTriggerTaps taps the outputs driving the trace filter's start and
+// stop jacks.  Only transmits carrying some pulse count, not empty ones.
+func (m *Machine) addTraceTriggerTaps() {
+	t := m.traceFilter
+	if t.start != nil {
+		m.tapPulsesTo(t.start, func(_ *Jack, val int) {
+			if val != 0 {
+				t.triggered = true
+			}
+		})
+	}
+	if t.stop != nil {
+		m.tapPulsesTo(t.stop, func(_ *Jack, val int) {
+			if val != 0 {
+				t.triggered = false
+			}
+		})
+	}
+}
+
+// recording returns whether signals logged now should be kept.
+func (t *traceFilter) recording() bool {
+	cycle := t.addCycle()
+	if cycle < t.from || (t.to != 0 && cycle >= t.to) {
+		return false
+	}
+	return t.triggered
+}
+
+// selects returns whether name matches any glob.
+func (t *traceFilter) selects(name string) bool {
+	if len(t.globs) == 0 {
+		return true
+	}
+	match, ok := t.selected[name]
+	if !ok {
+		for _, glob := range t.globs {
+			if match, _ = path.Match(glob, name); match {
+				break
+			}
+		}
+		t.selected[name] = match
+	}
+	return match
+}
+
+// LogPulse logs a pulse on the wire name if it is selected and in window.
+func (t *traceFilter) LogPulse(name string, bits int, value int64) {
+	if t.recording() && t.selects(name) {
+		t.Tracer.LogPulse(name, bits, value)
+	}
+}
+
+// LogValue logs the value of reg name if it is selected and in window.
+func (t *traceFilter) LogValue(name string, bits int, value int64) {
+	if t.recording() && t.selects(name) {
+		t.Tracer.LogValue(name, bits, value)
+	}
+}
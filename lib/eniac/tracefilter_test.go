@@ -0,0 +1,110 @@
+package eniac
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTraceFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracefilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd")
+
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "ts pf a2.* from=3 to=6")
+	m.Run(12)
+	m.Exec(&out, "te "+filename)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vcd := string(data)
+	if !strings.Contains(vcd, "a2.decade") || !strings.Contains(vcd, "a2.alpha") {
+		t.Errorf("missing a2 signals\n%s", vcd)
+	}
+	if strings.Contains(vcd, "a1.") {
+		t.Errorf("unexpected a1 signals\n%s", vcd)
+	}
+	for _, match := range regexp.MustCompile(`(?m)^#(\d+)$`).FindAllStringSubmatch(vcd, -1) {
+		time, _ := strconv.Atoi(match[1])
+		if time < 3*pulseTimesPerAddCycle || time > 6*pulseTimesPerAddCycle {
+			t.Errorf("change at #%d outside add cycles 3-6", time)
+		}
+	}
+}
+
+func TestTraceTriggers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracefilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd")
+
+	// a3 pulses 1-2 to start tracing after 2 add cycles, and a4 pulses 1-3 to
+	// stop it after 5, while a2 counts for 9.
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	for _, command := range []string{
+		"p 1-1 a3.5i", "s a3.rp5 2", "p a3.5o 1-2",
+		"p 1-1 a4.5i", "s a4.rp5 5", "p a4.5o 1-3",
+		"ts p a2.α start=1-2 stop=1-3",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Run(12)
+	m.Exec(&out, "te "+filename)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cycles []int
+	for _, match := range regexp.MustCompile(`(?m)^#(\d+)\nb0*1 !$`).FindAllStringSubmatch(string(data), -1) {
+		time, _ := strconv.Atoi(match[1])
+		cycles = append(cycles, time/pulseTimesPerAddCycle)
+	}
+	if want := []int{4, 5, 6}; !reflect.DeepEqual(cycles, want) {
+		t.Errorf("got a2.α pulses in add cycles %v, want %v\n%s", cycles, want, data)
+	}
+}
+
+func TestTraceFilterSyntax(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"ts pf a[1", "trace start: bad pattern a[1\n"},
+		{"ts pf from=x", "trace start: invalid add cycle x\n"},
+		{"ts pf from=5 to=5", "trace start: empty window from=5 to=5\n"},
+		{"ts pf start=a21.A", "trace start: invalid accumulator 21\n"},
+		{"ts pf every=2", "trace start: unknown option every\n"},
+		{"ts c a1.*", "trace start: only p and f traces select signals\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		m := newCounter(&out)
+		m.Exec(&out, test.command)
+		if got := out.String(); got != test.want {
+			t.Errorf("%s: got %q; want %q", test.command, got, test.want)
+		}
+	}
+}
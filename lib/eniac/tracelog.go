@@ -0,0 +1,103 @@
+package eniac
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// traceLog collects the text traces started by ts c (program pulse causes)
+// and ts r (register transfers) in the order they happen.
+//
+// Lines are written to a file as they are logged, so long traces don't fill
+// memory.  The file is the one named by ts, or else an unnamed temporary file
+// which WriteLog copies out at te.  A log without a file keeps its lines in
+// memory, for Lockstep which only wants the last few.
+type traceLog struct {
+	causes    bool
+	transfers bool
+	filename  string // Named by ts, if any
+	file      *os.File
+	w         *bufio.Writer
+	lines     []string // Lines logged, if there is no file
+
+	// Digit transfers seen so far in the current add cycle, which are logged
+	// once it ends.
+	addCycle int64
+	pending  []*transfer
+	sources  map[*Jack]*transfer
+}
+
+// newTraceLog starts a log of causes and/or transfers written to filename, or
+// to a temporary file if filename is "".
+func newTraceLog(causes, transfers bool, filename string) (*traceLog, error) {
+	var file *os.File
+	var err error
+	if filename != "" {
+		file, err = os.Create(filename)
+	} else if file, err = ioutil.TempFile("", "eniac-trace-"); err == nil {
+		// Only WriteLog reads the file back, through file, so it need not
+		// stay in the directory even if the simulator dies.
+		os.Remove(file.Name())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &traceLog{
+		causes:    causes,
+		transfers: transfers,
+		filename:  filename,
+		file:      file,
+		w:         bufio.NewWriterSize(file, 1<<16),
+	}, nil
+}
+
+func (t *traceLog) printf(format string, a ...interface{}) {
+	if t.w == nil {
+		t.lines = append(t.lines, fmt.Sprintf(format, a...))
+		return
+	}
+	fmt.Fprintf(t.w, format, a...)
+	t.w.WriteByte('\n')
+}
+
+// WriteLog writes the lines logged so far to w.
+func (t *traceLog) WriteLog(w io.Writer) error {
+	t.flushTransfers()
+	if t.w == nil {
+		bw := bufio.NewWriter(w)
+		for _, line := range t.lines {
+			fmt.Fprintln(bw, line)
+		}
+		return bw.Flush()
+	}
+	if err := t.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, t.file)
+	if _, seekErr := t.file.Seek(0, io.SeekEnd); err == nil {
+		err = seekErr
+	}
+	return err
+}
+
+// Close finishes writing the log and closes its file.
+func (t *traceLog) Close() error {
+	t.flushTransfers()
+	if t.w == nil {
+		return nil
+	}
+	err := t.w.Flush()
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	t.w = nil
+	return err
+}
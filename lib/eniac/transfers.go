@@ -0,0 +1,207 @@
+package eniac
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/jeredw/eniacsim/lib"
+)
+
+// transfer is the digit traffic sent by one output during an add cycle.
+// Numbers are sent as trains of pulses on each of 11 lines, so the value
+// transmitted is only known once the add cycle is over.
+type transfer struct {
+	source   *Jack
+	setting  string   // Adapter setting applied on the way, if any
+	trunks   []string // Data trays the output is plugged into
+	counts   [11]int  // Pulses sent on each line, least significant first
+	received []*Jack  // Inputs which accepted pulses
+}
+
+// addTransferTaps taps every plugged output which sends digits, so that ts r
+// logs each add cycle's digit traffic as register transfers, e.g.
+//
+//	cycle 812: a1.A (P0000000042) -> tray 1 -> a2.α, a3.β; a2 += 42; a3 += 42
+func (m *Machine) addTransferTaps() {
+	for _, j := range m.ratsNest.Jacks() {
+		if isRoutingJack(j) || len(j.Receivers) == 0 || sendsProgramPulses(j) {
+			continue
+		}
+		trunks := digitTrunks(j)
+		setting := m.adapterSetting(j)
+		// Permute adapters with a single receiver may bypass their output
+		// entirely, so account for their traffic when it reaches their input.
+		var permuters []*permuter
+		for _, r := range j.FinalReceivers() {
+			if p := m.hardwiredPermuter(r); p != nil {
+				permuters = append(permuters, p)
+			}
+		}
+		m.addTap(j, func(j *Jack, val int) {
+			if val == 0 || m.textLog == nil {
+				return
+			}
+			m.logTransfer(j, setting, trunks, val)
+			for _, p := range permuters {
+				if val := permuted(p, val); val != 0 {
+					m.logTransfer(p.out, m.adapterSetting(p.out), digitTrunks(p.out), val)
+				}
+			}
+		})
+	}
+}
+
+// logTransfer records val sent by source for the current add cycle.
+func (m *Machine) logTransfer(source *Jack, setting string, trunks []string, val int) {
+	t := m.textLog
+	if t.pending != nil && t.addCycle != m.cycle.AddCycle {
+		t.flushTransfers()
+	}
+	t.addCycle = m.cycle.AddCycle
+	if t.sources == nil {
+		t.sources = make(map[*Jack]*transfer)
+	}
+	x, ok := t.sources[source]
+	if !ok {
+		x = &transfer{source: source, setting: setting, trunks: trunks}
+		t.sources[source] = x
+		t.pending = append(t.pending, x)
+	}
+	for i := range x.counts {
+		if val&(1<<uint(i)) != 0 {
+			x.counts[i]++
+		}
+	}
+	for _, r := range source.FinalReceivers() {
+		if r.Disabled {
+			continue
+		}
+		x.receive(r)
+	}
+}
+
+func (x *transfer) receive(r *Jack) {
+	for _, y := range x.received {
+		if y == r {
+			return
+		}
+	}
+	x.received = append(x.received, r)
+}
+
+// flushTransfers logs the transfers of the last add cycle.
+func (t *traceLog) flushTransfers() {
+	for _, x := range t.pending {
+		t.printf("%s", x.String(t.addCycle))
+	}
+	t.pending = nil
+	t.sources = nil
+}
+
+// value decodes the pulses sent as a signed number and the form accumulators
+// display it in, e.g. M9999999958.  Complements sent on S include a final
+// +1 in the least significant decade, so counts may exceed 9 and carry.
+func (x *transfer) value() (int64, string) {
+	var n, place int64 = 0, 1
+	for i := 0; i < 10; i++ {
+		n += int64(x.counts[i]) * place
+		place *= 10
+	}
+	if x.counts[10]%2 == 1 {
+		n += place
+	}
+	n %= 2 * place
+	if n >= place {
+		return n - 2*place, fmt.Sprintf("M%010d", n-place)
+	}
+	return n, fmt.Sprintf("P%010d", n)
+}
+
+func (x *transfer) String(addCycle int64) string {
+	value, digits := x.value()
+	var b strings.Builder
+	fmt.Fprintf(&b, "cycle %d: %s", addCycle, plugName(x.source))
+	if x.setting != "" {
+		fmt.Fprintf(&b, " [%s]", x.setting)
+	}
+	fmt.Fprintf(&b, " (%s) -> ", digits)
+	if len(x.trunks) != 0 {
+		fmt.Fprintf(&b, "tray %s -> ", strings.Join(x.trunks, ","))
+	}
+	if len(x.received) == 0 {
+		b.WriteString("nothing")
+	}
+	var receivers, updates []string
+	for _, r := range x.received {
+		receivers = append(receivers, plugName(r))
+		if accDigitInput.MatchString(r.Name) {
+			acc := r.Name[:strings.IndexByte(r.Name, '.')]
+			if value < 0 {
+				updates = append(updates, fmt.Sprintf("%s -= %d", acc, -value))
+			} else {
+				updates = append(updates, fmt.Sprintf("%s += %d", acc, value))
+			}
+		}
+	}
+	sort.Strings(receivers)
+	sort.Strings(updates)
+	b.WriteString(strings.Join(receivers, ", "))
+	for _, u := range updates {
+		b.WriteString("; " + u)
+	}
+	return b.String()
+}
+
+// adapterSetting describes how the adapter driving output j changes digits,
+// or returns "" if j is not an adapter output.
+func (m *Machine) adapterSetting(j *Jack) string {
+	p := strings.Split(j.Name, ".")
+	if len(p) != 4 || p[0] != "ad" || p[2] != "o" {
+		return ""
+	}
+	i, _ := strconv.Atoi(p[3])
+	if !(i >= 1 && i <= 80) {
+		return ""
+	}
+	a := m.adapters
+	switch p[1] {
+	case "s":
+		return fmt.Sprintf("shift %d", a.shift[i-1].amount)
+	case "d":
+		return fmt.Sprintf("delete %d", a.del[i-1].digit)
+	case "sd":
+		return fmt.Sprintf("sd %d", a.sd[i-1].digit)
+	case "permute":
+		return "permute " + (&permuteSwitch{&a.permute[i-1]}).Get()
+	}
+	return ""
+}
+
+// hardwiredPermuter returns the permute adapter whose input is j if it sends
+// pulses directly to its only receiver rather than through its output.
+func (m *Machine) hardwiredPermuter(j *Jack) *permuter {
+	if !strings.HasPrefix(j.Name, "ad.permute.i.") {
+		return nil
+	}
+	i, _ := strconv.Atoi(strings.TrimPrefix(j.Name, "ad.permute.i."))
+	if !(i >= 1 && i <= 80) {
+		return nil
+	}
+	p := &m.adapters.permute[i-1]
+	if _, custom := getCustomPermuter(p.order); !custom || j.OtherSide == p.out {
+		return nil
+	}
+	return p
+}
+
+// permuted returns val as permuted by p.
+func permuted(p *permuter, val int) int {
+	s := val << 11
+	permuted := 0
+	for i := 0; i < 11; i++ {
+		permuted |= (s >> (p.shift[i] & 63)) & (1 << uint(i))
+	}
+	return permuted
+}
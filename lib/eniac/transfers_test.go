@@ -0,0 +1,60 @@
+package eniac
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTransferTrace(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	for _, command := range []string{
+		"set a1 42",
+		"p i.Io 1-1",
+		"p 1-1 a1.5i",
+		"s a1.op5 AS",
+		"p 1-1 a2.5i",
+		"s a2.op5 α",
+		"p 1-1 a3.5i",
+		"s a3.op5 α",
+		"p 1-1 a4.5i",
+		"s a4.op5 α",
+		"p a1.A 1",
+		"p 1 a2.α",
+		"p 1 ad.s.1",
+		"s ad.s.1 2",
+		"p ad.s.1 2",
+		"p 2 a3.α",
+		"p a1.S 3",
+		"p 3 a4.α",
+		"p 3 a4.β",
+		"p 1-1 a5.5i",
+		"s a5.op5 α",
+		"s ad.permute.1 0,2,1,0,0,0,0,0,0,0,0",
+		"p 1 ad.permute.1",
+		"p ad.permute.1 4",
+		"p 4 a5.α",
+		"ts r",
+		"b i",
+	} {
+		m.Exec(&out, command)
+	}
+	m.Cycle().SetTestMode()
+	m.Run(3)
+	if out.Len() != 0 {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	var log bytes.Buffer
+	m.textLog.WriteLog(&log)
+	// a4.β is not active so it does not receive anything.
+	want := "cycle 2: a1.S (M9999999958) -> tray 3 -> a4.α; a4 -= 42\n" +
+		"cycle 2: a1.A (P0000000042) -> tray 1 -> a2.α, ad.permute.1, ad.s.1; a2 += 42\n" +
+		"cycle 2: ad.permute.1 [permute 0,2,1,0,0,0,0,0,0,0,0] (P4200000000) -> tray 4 -> a5.α; a5 += 4200000000\n" +
+		"cycle 2: ad.s.1 [shift 2] (P0000004200) -> tray 2 -> a3.α; a3 += 4200\n"
+	if got := log.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := string(m.Units().Accumulator[4].Value()); got != "P 4200000000" {
+		t.Errorf("a5 = %s; want P 4200000000", got)
+	}
+}
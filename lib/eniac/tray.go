@@ -1,4 +1,4 @@
-package main
+package eniac
 
 import (
 	"fmt"
@@ -12,6 +12,8 @@ import (
 type Trays struct {
 	data    [20]*Jack
 	program [26][11]*Jack
+
+	tracer Tracer
 }
 
 func NewTrays() *Trays {
@@ -27,6 +29,27 @@ func NewTrays() *Trays {
 	return t
 }
 
+// AttachTracer connects a trace logger, which sees the pulses on each data
+// trunk as trunk.1-trunk.20 and on each program line as tray.A-1-tray.Z-11.
+func (t *Trays) AttachTracer(tracer Tracer) {
+	t.tracer = tracer
+}
+
+// tracedNets adds the trunks and program lines to nets if tracing.
+func (t *Trays) tracedNets(nets map[*Jack]tracedNet) {
+	if t.tracer == nil {
+		return
+	}
+	for i := range t.data {
+		nets[t.data[i]] = tracedNet{t.tracer, fmt.Sprintf("trunk.%d", i+1), 11}
+	}
+	for i := range t.program {
+		for j := range t.program[i] {
+			nets[t.program[i][j]] = tracedNet{t.tracer, fmt.Sprintf("tray.%c-%d", 'A'+i, j+1), 1}
+		}
+	}
+}
+
 func (t *Trays) FindJack(name string) (*Jack, error) {
 	dash := strings.IndexByte(name, '-')
 	if dash == -1 {
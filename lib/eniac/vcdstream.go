@@ -0,0 +1,260 @@
+package eniac
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// vcdStream is a Tracer which writes value changes to disk as time advances
+// instead of keeping them in memory, for traces too long for a wavedump.
+//
+// vcd files must define every signal before any value changes, so signals
+// are declared up front, and the definitions are written when time first
+// advances.  Signals first logged after that can't be added, and are left
+// out.
+type vcdStream struct {
+	filename string
+	file     *os.File
+	zw       *gzip.Writer // If filename ends in .gz
+	w        *bufio.Writer
+
+	defs    wavedump // Signals declared, without values
+	state   map[string]*streamSignal
+	started bool            // Definitions written
+	late    map[string]bool // Signals logged after started, and left out
+	touched []*streamSignal // Signals which may change at curTime
+	spare   []*streamSignal
+
+	pulses       bool
+	regs         bool
+	regCallbacks []func()
+	curTime      int
+}
+
+type streamSignal struct {
+	w       *waveform
+	value   int64 // Value at curTime
+	written int64 // Value last written
+	pulsed  bool  // Returns to 0 at the next time step
+	touched bool
+}
+
+// newVcdStream starts streaming a trace of pulses and/or regs to filename,
+// which is compressed if it ends in .gz.
+func newVcdStream(filename string, pulses, regs bool) (*vcdStream, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	t := &vcdStream{
+		filename: filename,
+		file:     file,
+		defs:     wavedump{signals: make(map[string]*waveform)},
+		state:    make(map[string]*streamSignal),
+		late:     make(map[string]bool),
+		pulses:   pulses,
+		regs:     regs,
+	}
+	if strings.HasSuffix(filename, ".gz") {
+		t.zw = gzip.NewWriter(file)
+		t.w = bufio.NewWriterSize(t.zw, 1<<16)
+	} else {
+		t.w = bufio.NewWriterSize(file, 1<<16)
+	}
+	return t, nil
+}
+
+// RegisterValueCallback enqueues callback to run periodically to poll
+// register values.
+func (t *vcdStream) RegisterValueCallback(update func()) {
+	if t.regs {
+		t.regCallbacks = append(t.regCallbacks, update)
+	}
+}
+
+// UpdateValues runs registered callbacks to update register values.
+func (t *vcdStream) UpdateValues() {
+	for i := range t.regCallbacks {
+		t.regCallbacks[i]()
+	}
+}
+
+// declare defines a signal of kind "wire" or "reg" which may be logged
+// later.  It has no effect once the definitions have been written.
+func (t *vcdStream) declare(kind, name string, bits int) {
+	if t.started || (kind == "wire" && !t.pulses) || (kind == "reg" && !t.regs) {
+		return
+	}
+	if _, ok := t.state[name]; ok {
+		return
+	}
+	w := newWaveform(kind, name, bits)
+	t.defs.signals[name] = w
+	t.state[name] = &streamSignal{w: w}
+}
+
+// signal returns the state of signal name, declaring it if that's still
+// possible, or nil if it was never declared.
+func (t *vcdStream) signal(kind, name string, bits int) *streamSignal {
+	s, ok := t.state[name]
+	if !ok {
+		if t.started {
+			t.late[name] = true
+			return nil
+		}
+		t.declare(kind, name, bits)
+		s = t.state[name]
+	}
+	return s
+}
+
+func (t *vcdStream) touch(s *streamSignal) {
+	if !s.touched {
+		s.touched = true
+		t.touched = append(t.touched, s)
+	}
+}
+
+// LogPulse logs a value sent on the wire name.
+func (t *vcdStream) LogPulse(name string, bits int, value int64) {
+	if !t.pulses {
+		return
+	}
+	if len(name) == 0 {
+		panic("empty name")
+	}
+	s := t.signal("wire", name, bits)
+	if s == nil {
+		return
+	}
+	if s.pulsed {
+		// Another driver already pulsed this wire now.
+		s.value |= value
+	} else {
+		s.value = value
+		s.pulsed = true
+	}
+	t.touch(s)
+}
+
+// LogValue logs the new value of reg name if it has changed.
+func (t *vcdStream) LogValue(name string, bits int, value int64) {
+	if !t.regs {
+		return
+	}
+	s := t.signal("reg", name, bits)
+	if s != nil && s.value != value {
+		s.value = value
+		t.touch(s)
+	}
+}
+
+// AdvanceTimestep writes changes for the current time and advances it.
+func (t *vcdStream) AdvanceTimestep() {
+	t.start()
+	t.writeChanges()
+	t.curTime++
+}
+
+// start writes the definitions of the signals declared so far, if it hasn't
+// already.
+func (t *vcdStream) start() {
+	if t.started {
+		return
+	}
+	t.started = true
+	scopes := t.defs.groupSignals()
+	nextId := "!"
+	for i := range scopes {
+		for _, name := range scopes[i].signals {
+			t.defs.signals[name].idCode = nextId
+			nextId = incrementIdCode(nextId)
+		}
+	}
+	t.defs.writeVcdHeader(t.w, time.Now(), scopes)
+}
+
+// writeChanges writes the values which changed at curTime, and schedules
+// pulses to return to 0 at the next time step.
+func (t *vcdStream) writeChanges() {
+	printedTimeMarker := false
+	touched := t.touched
+	t.touched = t.spare[:0]
+	for _, s := range touched {
+		s.touched = false
+		if s.value != s.written {
+			if !printedTimeMarker {
+				fmt.Fprintf(t.w, "#%d\n", t.curTime)
+				printedTimeMarker = true
+			}
+			writeVcdValue(t.w, s.value, s.w.bits)
+			io.WriteString(t.w, s.w.idCode+"\n")
+			s.written = s.value
+		}
+		if s.pulsed {
+			s.pulsed = false
+			s.value = 0
+			t.touch(s)
+		}
+	}
+	t.spare = touched[:0]
+}
+
+// lateSignals returns how many signals were left out because they were first
+// logged after the definitions were written.
+func (t *vcdStream) lateSignals() int {
+	return len(t.late)
+}
+
+// Close finishes writing the vcd file.
+func (t *vcdStream) Close() error {
+	t.start()
+	// Pulses at the current time end at the next.
+	t.writeChanges()
+	t.curTime++
+	t.writeChanges()
+	err := t.w.Flush()
+	if t.zw != nil {
+		if zerr := t.zw.Close(); err == nil {
+			err = zerr
+		}
+	}
+	if cerr := t.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// declareStreamSignals declares to the vcd being streamed every signal the
+// units may log, which is every register and the pulses on plugged jacks and
+// the nets they drive, less any the trace filter leaves out.
+func (m *Machine) declareStreamSignals() {
+	declare := func(kind, name string, bits int) {
+		if m.traceFilter == nil || m.traceFilter.selects(name) {
+			m.stream.declare(kind, name, bits)
+		}
+	}
+	m.tracer.declare = declare
+	m.stream.UpdateValues()
+	m.tracer.declare = nil
+
+	nets := m.tracedNets()
+	for _, j := range m.ratsNest.Jacks() {
+		if n, ok := nets[j]; ok {
+			declare("wire", n.name, n.bits)
+		} else if j.TraceBits != 0 {
+			declare("wire", j.Name, j.TraceBits)
+		}
+		if p := m.hardwiredPermuter(j); p != nil {
+			declare("wire", p.out.Name, p.out.TraceBits)
+		}
+	}
+	for _, name := range m.u.Initiate.TracedPulses() {
+		declare("wire", name, 1)
+	}
+}
@@ -0,0 +1,143 @@
+package eniac
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVcdStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcdstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd")
+	tr, err := newVcdStream(filename, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.RegisterValueCallback(func() {
+		tr.LogValue("Unit.reg", 6, 42)
+	})
+	tr.declare("wire", "Unit.signal2", 6)
+	tr.UpdateValues()
+	tr.LogPulse("Unit.signal", 1, 1)
+	tr.AdvanceTimestep()
+	tr.LogPulse("Unit.signal", 1, 1)
+	tr.LogPulse("Unit.signal2", 6, 42)
+	tr.LogPulse("Unit.late", 1, 1)
+	tr.AdvanceTimestep()
+	tr.LogPulse("Unit.signal", 1, 1)
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	got = got[strings.Index(got, "$timescale"):]
+	want := `$timescale 10us $end
+$scope module Unit $end
+$var reg 6 ! Unit.reg[5:0] $end
+$var wire 1 " Unit.signal $end
+$var wire 6 # Unit.signal2[5:0] $end
+$upscope $end
+$enddefinitions $end
+$dumpvars
+b000000 !
+0"
+b000000 #
+$end
+#0
+b101010 !
+1"
+#1
+b101010 #
+#2
+b000000 #
+#3
+0"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if tr.lateSignals() != 1 {
+		t.Errorf("got %d late signals, want 1", tr.lateSignals())
+	}
+}
+
+func TestStreamCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcdstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "trace.vcd.gz")
+
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "ts pfc "+filename)
+	m.Run(12)
+	m.Exec(&out, "te")
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	fd, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	zr, err := gzip.NewReader(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "$var wire 11 ") || !strings.Contains(string(data), "a2.decade") {
+		t.Errorf("unexpected trace\n%s", data)
+	}
+	if _, err := os.Stat(filename + ".log"); err != nil {
+		t.Errorf("missing cause log: %s", err)
+	}
+}
+
+func TestStreamClosedOnExit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcdstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "out.vcd")
+
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "ts p "+filename)
+	m.Run(12)
+	m.CloseTraces(&out)
+	if out.Len() != 0 {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signals which first pulse after the trace starts are declared up front.
+	for _, name := range []string{"a1.A[10:0]", "a2.5i", "trunk.1[10:0]"} {
+		if !strings.Contains(string(data), " "+name+" $end") {
+			t.Errorf("missing %s in trace\n%s", name, data)
+		}
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("got %d files, want only %s", len(files), filename)
+	}
+}
@@ -0,0 +1,245 @@
+package eniac
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// watchpoint stops the machine when a condition on a unit's state becomes
+// true, or when a value changes.  Watchpoints are checked at the end of each
+// add cycle.
+type watchpoint struct {
+	id      int
+	spec    string                 // As given to the watch command
+	value   func() (int64, string) // Current value and its display form
+	op      string                 // Comparison or "changes"
+	operand int64
+	last    int64 // Value at the last check
+	hit     bool  // Condition held at the last check
+}
+
+// Watch adds a watchpoint described by f, the arguments of a watch command,
+// and returns its number.  Watchpoints take the forms
+//
+//	value changes
+//	value op operand
+//
+// where value is aN (an accumulator), p.X stage (a master programmer stepper
+// stage, 1-6) or fN.arg (a function table argument), and op is one of == !=
+// < <= > >=.  Accumulator operands may be signed numbers or written as
+// digits, e.g. P0000000042 or M9999999958.
+func (m *Machine) Watch(f []string) (int, error) {
+	if len(f) < 2 {
+		return 0, fmt.Errorf("watch syntax: watch value op operand")
+	}
+	w := &watchpoint{spec: strings.Join(f, " ")}
+	var valueArgs []string
+	if f[len(f)-1] == "changes" {
+		w.op = "changes"
+		valueArgs = f[:len(f)-1]
+	} else {
+		if len(f) < 3 {
+			return 0, fmt.Errorf("watch syntax: watch value op operand")
+		}
+		w.op = f[len(f)-2]
+		switch w.op {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return 0, fmt.Errorf("invalid watch comparison %s", w.op)
+		}
+		operand, err := parseWatchOperand(f[len(f)-1])
+		if err != nil {
+			return 0, err
+		}
+		w.operand = operand
+		valueArgs = f[:len(f)-2]
+	}
+	value, err := m.watchValue(valueArgs)
+	if err != nil {
+		return 0, err
+	}
+	w.value = value
+	w.last, _ = value()
+	w.hit = w.test(w.last)
+	m.nextWatch++
+	w.id = m.nextWatch
+	m.watches = append(m.watches, w)
+	m.updateAddCycleHook()
+	return w.id, nil
+}
+
+// Unwatch removes watchpoint id.
+func (m *Machine) Unwatch(id int) error {
+	for i, w := range m.watches {
+		if w.id == id {
+			m.watches = append(m.watches[:i], m.watches[i+1:]...)
+			m.updateAddCycleHook()
+			return nil
+		}
+	}
+	return fmt.Errorf("no watchpoint %d", id)
+}
+
+func (m *Machine) watchValue(f []string) (func() (int64, string), error) {
+	name := strings.Join(f, " ")
+	switch {
+	case len(f) == 1 && len(name) > 1 && name[0] == 'a':
+		n, err := strconv.Atoi(name[1:])
+		if err != nil || !(n >= 1 && n <= 20) {
+			return nil, fmt.Errorf("invalid accumulator %s", name)
+		}
+		a := m.u.Accumulator[n-1]
+		return func() (int64, string) {
+			value := string(a.Value())
+			return accumulatorInt(value), value
+		}, nil
+	case len(f) == 2 && strings.HasPrefix(f[0], "p.") && len(f[0]) == 3 && f[1] == "stage":
+		stepper := f[0][2]
+		if m.u.Mp.Stage(stepper) == 0 {
+			return nil, fmt.Errorf("invalid stepper %s", f[0])
+		}
+		return func() (int64, string) {
+			stage := m.u.Mp.Stage(stepper)
+			return int64(stage), strconv.Itoa(stage)
+		}, nil
+	case len(f) == 1 && len(name) == 6 && name[0] == 'f' && strings.HasSuffix(name, ".arg"):
+		n := int(name[1] - '0')
+		if !(n >= 1 && n <= 3) {
+			return nil, fmt.Errorf("invalid function table %s", name)
+		}
+		ft := m.u.Ft[n-1]
+		return func() (int64, string) {
+			return int64(ft.Arg()), strconv.Itoa(ft.Arg())
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid watch value %s", name)
+}
+
+// accumulatorInt converts an accumulator value like "M 9999999958" to a
+// signed number.
+func accumulatorInt(value string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(value[1:]), 10, 64)
+	if value[0] == 'M' {
+		n -= 10000000000
+	}
+	return n
+}
+
+func parseWatchOperand(s string) (int64, error) {
+	if len(s) > 1 && (s[0] == 'P' || s[0] == 'M') {
+		if _, err := strconv.ParseUint(s[1:], 10, 64); err != nil || len(s) > 11 {
+			return 0, fmt.Errorf("invalid accumulator value %s", s)
+		}
+		return accumulatorInt(s), nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid watch operand %s", s)
+	}
+	return n, nil
+}
+
+func (w *watchpoint) test(v int64) bool {
+	switch w.op {
+	case "==":
+		return v == w.operand
+	case "!=":
+		return v != w.operand
+	case "<":
+		return v < w.operand
+	case "<=":
+		return v <= w.operand
+	case ">":
+		return v > w.operand
+	case ">=":
+		return v >= w.operand
+	}
+	return false
+}
+
+// updateAddCycleHook has the cycle unit call endAddCycle only when something
+// needs it: watchpoints to check, register transfers to log, a profile or
+// timeline to sample or a capture armed.  Anything which changes one of those
+// must call it again.
+func (m *Machine) updateAddCycleHook() {
+	if m.replaying {
+		m.cycle.Io.EndAddCycle = nil
+	} else if len(m.watches) != 0 || (m.textLog != nil && m.textLog.transfers) ||
+		(m.profile != nil && m.profile.running) || m.capture != nil ||
+		m.timeline != nil {
+		m.cycle.Io.EndAddCycle = m.endAddCycle
+	} else {
+		m.cycle.Io.EndAddCycle = nil
+	}
+}
+
+// endAddCycle is called by the cycle unit at the end of each add cycle, while
+// installed by updateAddCycleHook.
+func (m *Machine) endAddCycle() {
+	if m.textLog != nil {
+		m.textLog.flushTransfers()
+	}
+	if m.profile != nil && m.profile.running {
+		m.sampleProfile()
+	}
+	if m.timeline != nil {
+		m.sampleTimeline()
+	}
+	m.checkWatches()
+	if m.capture != nil {
+		m.checkCapture()
+	}
+}
+
+func (m *Machine) checkWatches() {
+	for _, w := range m.watches {
+		v, text := w.value()
+		var fire bool
+		if w.op == "changes" {
+			fire = v != w.last
+		} else {
+			hit := w.test(v)
+			fire = hit && !w.hit
+			w.hit = hit
+		}
+		w.last = v
+		if fire {
+			fmt.Fprintf(m.debugger.Io.Output, "[watch %d] %s (%s)\n", w.id, w.spec, text)
+			m.debugger.Io.Stop()
+		}
+	}
+}
+
+func (m *Machine) doWatch(w io.Writer, f []string) {
+	if len(f) == 1 {
+		for _, wp := range m.watches {
+			fmt.Fprintf(w, "%d: watch %s\n", wp.id, wp.spec)
+		}
+		return
+	}
+	if _, err := m.Watch(f[1:]); err != nil {
+		fmt.Fprintln(w, err)
+	}
+}
+
+func (m *Machine) doUnwatch(w io.Writer, f []string) {
+	if len(f) != 2 {
+		fmt.Fprintln(w, "unwatch syntax: unwatch n|all")
+		return
+	}
+	if f[1] == "all" {
+		m.watches = nil
+		m.updateAddCycleHook()
+		return
+	}
+	id, err := strconv.Atoi(f[1])
+	if err != nil {
+		fmt.Fprintf(w, "Invalid watchpoint %s\n", f[1])
+		return
+	}
+	if err := m.Unwatch(id); err != nil {
+		fmt.Fprintf(w, "unwatch: %s\n", err)
+	}
+}
@@ -0,0 +1,92 @@
+package eniac
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jeredw/eniacsim/lib/units"
+)
+
+func TestWatch(t *testing.T) {
+	tests := []struct {
+		watch string
+		want  string
+		a2    string
+	}{
+		{"watch a2 == P0000000003", "[watch 1] a2 == P0000000003 (P 0000000003)\n", "P 0000000003"},
+		{"watch a2 >= 5", "[watch 1] a2 >= 5 (P 0000000005)\n", "P 0000000005"},
+		{"watch a2 changes", "[watch 1] a2 changes (P 0000000001)\n", "P 0000000001"},
+		{"watch a2 < 0", "", "P 0000000009"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		m := newCounter(&out)
+		m.Exec(&out, test.watch)
+		stopped := m.Run(20)
+		if got := out.String(); got != test.want {
+			t.Errorf("%s: got %q; want %q", test.watch, got, test.want)
+		}
+		if stopped != (test.want != "") {
+			t.Errorf("%s: Run returned %v", test.watch, stopped)
+		}
+		if got := string(m.Units().Accumulator[1].Value()); got != test.a2 {
+			t.Errorf("%s: a2 = %s; want %s", test.watch, got, test.a2)
+		}
+	}
+}
+
+func TestWatchCommands(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMachine(MachineConn{Output: &out})
+	for _, command := range []string{
+		"watch a5 == P0000000042",
+		"watch p.A stage == 3",
+		"watch f2.arg > 99",
+		"watch a21 changes",
+		"watch p.I stage == 1",
+		"watch a1 ~ 3",
+		"unwatch 2",
+		"watch",
+	} {
+		m.Exec(&out, command)
+	}
+	want := "invalid accumulator a21\n" +
+		"invalid stepper p.I\n" +
+		"invalid watch comparison ~\n" +
+		"1: watch a5 == P0000000042\n" +
+		"3: watch f2.arg > 99\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWatchWhenStopped(t *testing.T) {
+	// A breakpoint stops partway through the add cycle where a2 changes, and
+	// the rest of that add cycle still checks watches.
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Exec(&out, "break a2.α")
+	m.Exec(&out, "watch a2 changes")
+	m.Run(20)
+	want := "[break 1] a2.α\n" +
+		"[watch 1] a2 changes (P 0000000001)\n"
+	if got := out.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestWatchStepPulses(t *testing.T) {
+	var out bytes.Buffer
+	m := newCounter(&out)
+	m.Exec(&out, "s cy.op 1p")
+	m.Exec(&out, "watch a2 == P0000000002")
+	for i := 0; i < 20*5; i++ {
+		m.Step()
+	}
+	if got, want := out.String(), "[watch 1] a2 == P0000000002 (P 0000000002)\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if m.AddCycle() != 5 || m.Cycle().Mode() != units.OnePulse {
+		t.Errorf("stepped to add cycle %d in mode %d; want 5 in 1p", m.AddCycle(), m.Cycle().Mode())
+	}
+}
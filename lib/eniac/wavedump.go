@@ -1,4 +1,4 @@
-package main
+package eniac
 
 import (
 	"fmt"
@@ -34,10 +34,19 @@ func newWaveform(kind string, name string, bits int) *waveform {
 	}
 }
 
+// pulseTimesPerAddCycle is how many time steps the cycling unit advances the
+// trace by each add cycle.
+const pulseTimesPerAddCycle = 20
+
 type wavedump struct {
 	signals map[string]*waveform
 	curTime int
 
+	// If limit is nonzero, only about the last limit time steps are kept,
+	// starting from start.  Older values are discarded as time advances.
+	limit int
+	start int
+
 	pulses       bool
 	regs         bool
 	regCallbacks []func()
@@ -70,6 +79,52 @@ func (t *wavedump) RegisterValueCallback(update func()) {
 // AdvanceTimestep advances the current signal time.
 func (t *wavedump) AdvanceTimestep() {
 	t.curTime++
+	if t.limit > 0 && t.curTime-t.start >= 2*t.limit {
+		t.trim(t.curTime - t.limit)
+	}
+}
+
+// trim discards values from before time from.  Registers keep the value they
+// held at from.
+func (t *wavedump) trim(from int) {
+	for _, s := range t.signals {
+		s.values = s.valuesFrom(from, t.curTime+1)
+	}
+	t.start = from
+}
+
+// valuesFrom returns a copy of the values of s between times from and to.
+func (s *waveform) valuesFrom(from, to int) []datapoint {
+	i := sort.Search(len(s.values), func(i int) bool { return s.values[i].time >= from })
+	j := sort.Search(len(s.values), func(i int) bool { return s.values[i].time > to })
+	values := make([]datapoint, 0, j-i+1)
+	if s.kind == "reg" && i > 0 && (i == len(s.values) || s.values[i].time > from) {
+		values = append(values, datapoint{from, s.values[i-1].value})
+	}
+	if i < j {
+		values = append(values, s.values[i:j]...)
+	}
+	return values
+}
+
+// window returns a copy of t holding only values between times from and to.
+func (t *wavedump) window(from, to int) *wavedump {
+	w := &wavedump{
+		signals: make(map[string]*waveform, len(t.signals)),
+		curTime: to,
+		start:   from,
+		pulses:  t.pulses,
+		regs:    t.regs,
+	}
+	for name, s := range t.signals {
+		w.signals[name] = &waveform{
+			kind:   s.kind,
+			name:   s.name,
+			bits:   s.bits,
+			values: s.valuesFrom(from, to+1),
+		}
+	}
+	return w
 }
 
 // UpdateValues runs registered callbacks to update register values.
@@ -95,6 +150,11 @@ func (t *wavedump) LogPulse(name string, bits int, value int64) {
 		t.signals[name] = s
 	}
 	numValues := len(s.values)
+	if numValues > 1 && s.values[numValues-1].time == t.curTime+1 {
+		// Another driver already pulsed this wire now.
+		s.values[numValues-2].value |= value
+		return
+	}
 	// Pulses implicitly go back to 0 at the next time step.
 	// In case there is some actual different value at the next time step,
 	// replace the implicitly added zero.
@@ -124,35 +184,15 @@ func (t *wavedump) LogValue(name string, bits int, value int64) {
 }
 
 func (t *wavedump) WriteVcd(w io.Writer, ts time.Time) {
-	fmt.Fprintf(w, "$version Generated by eniacsim $end\n")
-	fmt.Fprintf(w, "$date %s $end\n", ts.Format(time.UnixDate))
-	fmt.Fprintf(w, "$timescale 10us $end\n")
-	nextId := "!"
 	scopes := t.groupSignals()
-	for i := range scopes {
-		fmt.Fprintf(w, "$scope module %s $end\n", scopes[i].name)
-		for _, signalName := range scopes[i].signals {
-			s := t.signals[signalName]
-			varName := signalName
-			if s.bits > 1 {
-				varName += fmt.Sprintf("[%d:0]", s.bits-1)
-			}
-			fmt.Fprintf(w, "$var %s %d %s %s $end\n", s.kind, s.bits, nextId, greekToAscii(varName))
-			s.idCode = nextId
-			nextId = incrementIdCode(nextId)
-		}
-		fmt.Fprintf(w, "$upscope $end\n")
-	}
-	fmt.Fprintf(w, "$enddefinitions $end\n")
-	fmt.Fprintf(w, "$dumpvars\n")
+	nextId := "!"
 	for i := range scopes {
 		for _, name := range scopes[i].signals {
-			s := t.signals[name]
-			writeVcdValue(w, 0, s.bits)
-			fmt.Fprintf(w, "%s\n", s.idCode)
+			t.signals[name].idCode = nextId
+			nextId = incrementIdCode(nextId)
 		}
 	}
-	fmt.Fprintf(w, "$end\n")
+	t.writeVcdHeader(w, ts, scopes)
 	now := 0
 	for now <= t.curTime+1 {
 		printedTimeMarker := false
@@ -172,7 +212,7 @@ func (t *wavedump) WriteVcd(w io.Writer, ts time.Time) {
 						printedTimeMarker = true
 					}
 					writeVcdValue(w, p.value, s.bits)
-					fmt.Fprintf(w, "%s\n", s.idCode)
+					io.WriteString(w, s.idCode+"\n")
 					s.curIndex++
 					// The next event time might be for this signal.
 					goto redo
@@ -186,6 +226,36 @@ func (t *wavedump) WriteVcd(w io.Writer, ts time.Time) {
 	}
 }
 
+// writeVcdHeader writes definitions for the signals in scopes, using their
+// assigned id codes, and initial values of 0.
+func (t *wavedump) writeVcdHeader(w io.Writer, ts time.Time, scopes []scope) {
+	fmt.Fprintf(w, "$version Generated by eniacsim $end\n")
+	fmt.Fprintf(w, "$date %s $end\n", ts.Format(time.UnixDate))
+	fmt.Fprintf(w, "$timescale 10us $end\n")
+	for i := range scopes {
+		fmt.Fprintf(w, "$scope module %s $end\n", scopes[i].name)
+		for _, signalName := range scopes[i].signals {
+			s := t.signals[signalName]
+			varName := signalName
+			if s.bits > 1 {
+				varName += fmt.Sprintf("[%d:0]", s.bits-1)
+			}
+			fmt.Fprintf(w, "$var %s %d %s %s $end\n", s.kind, s.bits, s.idCode, greekToAscii(varName))
+		}
+		fmt.Fprintf(w, "$upscope $end\n")
+	}
+	fmt.Fprintf(w, "$enddefinitions $end\n")
+	fmt.Fprintf(w, "$dumpvars\n")
+	for i := range scopes {
+		for _, name := range scopes[i].signals {
+			s := t.signals[name]
+			writeVcdValue(w, 0, s.bits)
+			io.WriteString(w, s.idCode+"\n")
+		}
+	}
+	fmt.Fprintf(w, "$end\n")
+}
+
 type scope struct {
 	name    string
 	signals []string
@@ -223,19 +293,18 @@ func (t *wavedump) groupSignals() []scope {
 }
 
 func writeVcdValue(w io.Writer, value int64, bits int) {
+	var buf [66]byte
+	b := buf[:0]
 	if bits != 1 {
-		fmt.Fprintf(w, "b")
+		b = append(b, 'b')
 	}
 	for i := bits - 1; i >= 0; i-- {
-		if value&(1<<i) != 0 {
-			fmt.Fprintf(w, "1")
-		} else {
-			fmt.Fprintf(w, "0")
-		}
+		b = append(b, byte('0'+(value>>uint(i))&1))
 	}
 	if bits != 1 {
-		fmt.Fprintf(w, " ")
+		b = append(b, ' ')
 	}
+	w.Write(b)
 }
 
 func incrementIdCode(idCode string) string {
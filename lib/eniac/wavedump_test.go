@@ -1,4 +1,4 @@
-package main
+package eniac
 
 import (
 	"bytes"
@@ -241,3 +241,41 @@ func TestRegTracing(t *testing.T) {
 	}
 	assertWavedumpsAreEqual(t, tr, &want)
 }
+
+func TestLimitedTracing(t *testing.T) {
+	tr := NewWavedump(true, true)
+	tr.limit = 2
+	tr.LogValue("Unit.reg", 6, 42)
+	for i := 0; i < 5; i++ {
+		tr.LogPulse("Unit.signal", 1, 1)
+		tr.AdvanceTimestep()
+	}
+	// Trimmed at time 4 to the last 2 time steps.
+	want := wavedump{
+		signals: map[string]*waveform{
+			"Unit.reg": {
+				kind:   "reg",
+				name:   "Unit.reg",
+				bits:   6,
+				values: []datapoint{{2, 42}},
+			},
+			"Unit.signal": {
+				kind:   "wire",
+				name:   "Unit.signal",
+				bits:   1,
+				values: []datapoint{{2, 1}, {3, 1}, {4, 1}, {5, 0}},
+			},
+		},
+		curTime: 5,
+	}
+	assertWavedumpsAreEqual(t, tr, &want)
+	if tr.start != 2 {
+		t.Errorf("start = %d; want 2", tr.start)
+	}
+
+	w := tr.window(3, 3)
+	want.signals["Unit.reg"].values = []datapoint{{3, 42}}
+	want.signals["Unit.signal"].values = []datapoint{{3, 1}, {4, 1}}
+	want.curTime = 3
+	assertWavedumpsAreEqual(t, w, &want)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -27,14 +28,26 @@ type Jack struct {
 
 	OutputConnected bool // to skip sending 1pp when S outputs not connected
 	Disabled        bool // to skip work for inactive accum inputs
+	TraceBits       int  // width of pulses handlers log when traced, 0 if none
 
 	finalReceivers []*Jack // receivers after routing
+	sendTo         []*Jack // finalReceivers, or a stand-in applying filters
+	filters        []jackFilter
 
 	visited  bool
 	forward  bool // jack forwards inputs
 	polarity int  // polarity (0=unspecified, 1=input, 2=output, 3=both)
 
 	OtherSide *Jack // jack for other side of adapter
+
+	Tap JackHandler // if set, called on every transmit for debugging
+}
+
+// jackFilter loses some pulses sent to certain receivers of a jack.
+type jackFilter struct {
+	to     map[*Jack]bool
+	filter func(int) int
+	val    int // Pulses passed for the current transmit
 }
 
 func newJack(name string, onReceive JackHandler, onTransmit JackHandler) *Jack {
@@ -64,8 +77,11 @@ func NewRoutingJack(name string, polarity int) *Jack {
 // Transmit sends val on jack j, invoking receiver callbacks for each connected
 // receiver and afterwards invoking j's transmit callback.
 func (j *Jack) Transmit(val int) {
+	if j.Tap != nil {
+		j.Tap(j, val)
+	}
 	transmitted := false
-	for _, r := range j.finalReceivers {
+	for _, r := range j.sendTo {
 		if !r.Disabled {
 			transmitted = true
 			r.OnReceive(r, val)
@@ -76,6 +92,60 @@ func (j *Jack) Transmit(val int) {
 	}
 }
 
+// Filter passes pulses sent from j to the receivers in to through filter,
+// which returns those pulses in a value that get through.  filter is called
+// once per transmit, however many receivers it applies to.  This simulates
+// faulty cabling, so taps and the transmit callback still see every pulse j
+// sends.  Filters are removed by ClearFilters and whenever cabling changes.
+func (j *Jack) Filter(to []*Jack, filter func(int) int) {
+	f := jackFilter{to: make(map[*Jack]bool), filter: filter}
+	for _, r := range to {
+		f.to[r] = true
+	}
+	j.filters = append(j.filters, f)
+	j.sendTo = []*Jack{&Jack{Name: j.Name, OnReceive: j.receiveFiltered}}
+	if j.OtherSide != nil {
+		// Adapters must send through j rather than straight to its receiver.
+		(j.OtherSide).OtherSide = j
+	}
+}
+
+// ClearFilters removes filters added by Filter.
+func (j *Jack) ClearFilters() {
+	j.filters = nil
+	j.sendTo = j.finalReceivers
+	if j.OtherSide != nil && len(j.finalReceivers) == 1 {
+		(j.OtherSide).OtherSide = j.finalReceivers[0]
+	}
+}
+
+// receiveFiltered stands in for j's receivers while it has filters.
+func (j *Jack) receiveFiltered(_ *Jack, val int) {
+	for i := range j.filters {
+		j.filters[i].val = j.filters[i].filter(val)
+	}
+	for _, r := range j.finalReceivers {
+		if r.Disabled {
+			continue
+		}
+		v := val
+		for i := range j.filters {
+			if j.filters[i].to[r] {
+				v &= j.filters[i].val
+			}
+		}
+		if v != 0 {
+			r.OnReceive(r, v)
+		}
+	}
+}
+
+// FinalReceivers returns the non-routing jacks that receive pulses sent on j,
+// after following connections through trays and pulse amplifiers.
+func (j *Jack) FinalReceivers() []*Jack {
+	return j.finalReceivers
+}
+
 func (j *Jack) String() string {
 	return j.Name
 }
@@ -120,6 +190,28 @@ func Connect(r *RatsNest, j1, j2 *Jack) error {
 	return nil
 }
 
+// Disconnect removes a connection made by Connect.
+func Disconnect(r *RatsNest, j1, j2 *Jack) error {
+	removed1 := j1.removeReceiver(j2)
+	removed2 := j2.removeReceiver(j1)
+	if !removed1 && !removed2 {
+		return fmt.Errorf("%s is not connected to %s", j1, j2)
+	}
+	r.updateFinalReceivers()
+	return nil
+}
+
+func (j *Jack) removeReceiver(r *Jack) bool {
+	for i := range j.Receivers {
+		if j.Receivers[i] == r {
+			j.Receivers = append(j.Receivers[:i], j.Receivers[i+1:]...)
+			j.OutputConnected = len(j.Receivers) != 0
+			return true
+		}
+	}
+	return false
+}
+
 func (j *Jack) isInput() bool {
 	switch j.polarity {
 	case 0:
@@ -154,10 +246,22 @@ func NewRatsNest() *RatsNest {
 	}
 }
 
+// Jacks returns every jack that has been connected, sorted by name.
+func (r *RatsNest) Jacks() []*Jack {
+	jacks := make([]*Jack, 0, len(r.jacks))
+	for _, jack := range r.jacks {
+		jacks = append(jacks, jack)
+	}
+	sort.Slice(jacks, func(i, j int) bool { return jacks[i].Name < jacks[j].Name })
+	return jacks
+}
+
 func (r *RatsNest) updateFinalReceivers() {
 	for _, jack := range r.jacks {
 		if jack.isOutput() && !jack.forward {
 			jack.finalReceivers = findFinalReceivers(jack, make([]*Jack, 0, 4))
+			jack.filters = nil
+			jack.sendTo = jack.finalReceivers
 			if jack.OtherSide != nil {
 				// When adapters have only one connected receiver, hardwire it
 				if len(jack.finalReceivers) == 1 {
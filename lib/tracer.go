@@ -13,6 +13,7 @@ type Tracer interface {
 	// LogValue records the instantaneous value of a register.
 	LogValue(signalName string, bits int, value int64)
 	// LogPulse records a momentary pulse on a signal which implicitly returns to
-	// 0 at the next time step.
+	// 0 at the next time step.  Pulses logged on the same signal in one time
+	// step, e.g. from several outputs driving one trunk, are ORed together.
 	LogPulse(signalName string, bits int, value int64)
 }
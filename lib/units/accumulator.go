@@ -3,6 +3,7 @@ package units
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strconv"
 
 	. "github.com/jeredw/eniacsim/lib"
@@ -49,6 +50,11 @@ type Accumulator struct {
 	left  *Accumulator
 	right *Accumulator
 
+	faulty      bool   // If true, apply the injected faults below
+	stuckMask   uint64 // Decades held at a fixed digit
+	stuckDigits uint64 // Digits for stuck decades (BCD)
+	carryMask   uint64 // Carry ffs which never set (BCD)
+
 	unit        int // Unit number 0-19
 	tracer      Tracer
 	valueString []byte
@@ -119,6 +125,60 @@ func (u *Accumulator) Clock(cyc Pulse) {
 	}
 }
 
+// ClockFaulty is Clock for an accumulator with injected faults.
+func (u *Accumulator) ClockFaulty(cyc Pulse) {
+	u.applyFaults()
+	u.Clock(cyc)
+	u.applyFaults()
+}
+
+// Faulty returns true if faults have been injected.
+func (u *Accumulator) Faulty() bool {
+	return u.faulty
+}
+
+// SetStuckDecade simulates a failed counter by holding decade (1-10, counting
+// from the least significant) at digit.
+func (u *Accumulator) SetStuckDecade(decade, digit int) error {
+	if !(decade >= 1 && decade <= 10) {
+		return fmt.Errorf("invalid decade %d", decade)
+	}
+	if !(digit >= 0 && digit <= 9) {
+		return fmt.Errorf("invalid digit %d", digit)
+	}
+	shift := uint(4 * (decade - 1))
+	u.stuckMask |= 0xf << shift
+	u.stuckDigits = u.stuckDigits&^(0xf<<shift) | uint64(digit)<<shift
+	u.faulty = true
+	u.applyFaults()
+	return nil
+}
+
+// SetDroppedCarry simulates a failed carry flip-flop in decade (1-10), so
+// that carries out of that decade are lost.
+func (u *Accumulator) SetDroppedCarry(decade int) error {
+	if !(decade >= 1 && decade <= 10) {
+		return fmt.Errorf("invalid decade %d", decade)
+	}
+	u.carryMask |= 1 << uint(4*(decade-1))
+	u.faulty = true
+	return nil
+}
+
+// ClearFaults removes any faults set by SetStuckDecade or SetDroppedCarry.
+func (u *Accumulator) ClearFaults() {
+	u.faulty = false
+	u.stuckMask = 0
+	u.stuckDigits = 0
+	u.carryMask = 0
+}
+
+func (u *Accumulator) applyFaults() {
+	u.decade = u.decade&^u.stuckMask | u.stuckDigits
+	u.carry &^= u.carryMask
+	u.carry2 &^= u.carryMask
+}
+
 func (u *Accumulator) doTenp() {
 	if u.activeProgram&(opA|opAS|opS) != 0 {
 		increment := u.decade + 0x1111111111
@@ -374,7 +434,7 @@ func (u *Accumulator) terminal(name string) string {
 }
 
 func (u *Accumulator) newDigitInput(name string, programMask int) *Jack {
-	return NewInput(u.terminal(name), func(j *Jack, val int) {
+	jack := NewInput(u.terminal(name), func(j *Jack, val int) {
 		if u.activeProgram&programMask == 0 || j.Disabled {
 			panic("inactive acc input should be skipped")
 		}
@@ -383,6 +443,8 @@ func (u *Accumulator) newDigitInput(name string, programMask int) *Jack {
 			u.tracer.LogPulse(j.Name, 11, int64(val))
 		}
 	})
+	jack.TraceBits = 11
+	return jack
 }
 
 func (u *Accumulator) receive(value int) {
@@ -410,7 +472,7 @@ func (u *Accumulator) receive(value int) {
 }
 
 func (u *Accumulator) newProgramInput(name string, which int) *Jack {
-	return NewInput(u.terminal(name), func(j *Jack, val int) {
+	jack := NewInput(u.terminal(name), func(j *Jack, val int) {
 		if val == 1 {
 			u.trigger(which)
 			if u.tracer != nil {
@@ -418,6 +480,8 @@ func (u *Accumulator) newProgramInput(name string, which int) *Jack {
 			}
 		}
 	})
+	jack.TraceBits = 1
+	return jack
 }
 
 func (u *Accumulator) trigger(input int) {
@@ -441,11 +505,24 @@ func (u *Accumulator) trigger(input int) {
 }
 
 func (u *Accumulator) newOutput(name string, width int) *Jack {
-	return NewOutput(u.terminal(name), func(j *Jack, val int) {
+	jack := NewOutput(u.terminal(name), func(j *Jack, val int) {
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, width, int64(val))
 		}
 	})
+	jack.TraceBits = width
+	return jack
+}
+
+// Busy returns true while any program is in progress.
+func (u *Accumulator) Busy() bool {
+	return u.inff2 != 0
+}
+
+// ActivePrograms returns a bitmask with bit i set while program i+1 is in
+// progress.
+func (u *Accumulator) ActivePrograms() int {
+	return u.inff2
 }
 
 func (u *Accumulator) Stat() string {
@@ -500,6 +577,60 @@ func (u *Accumulator) State() json.RawMessage {
 	return result
 }
 
+type accSnapshot struct {
+	Sign            bool   `json:"sign"`
+	Decade          uint64 `json:"decade"`
+	Carry           uint64 `json:"carry"`
+	Carry2          uint64 `json:"carry2"`
+	Inff1           int    `json:"inff1"`
+	Inff2           int    `json:"inff2"`
+	Repeating       bool   `json:"repeating"`
+	RepeatCount     int    `json:"repeatCount"`
+	AfterFirstRp    bool   `json:"afterFirstRp"`
+	ActiveProgram   int    `json:"activeProgram"`
+	ExternalProgram int    `json:"externalProgram"`
+}
+
+// Snapshot returns the dynamic state of the accumulator (but not switches).
+func (u *Accumulator) Snapshot() json.RawMessage {
+	s := accSnapshot{
+		Sign:            u.sign,
+		Decade:          u.decade,
+		Carry:           u.carry,
+		Carry2:          u.carry2,
+		Inff1:           u.inff1,
+		Inff2:           u.inff2,
+		Repeating:       u.repeating,
+		RepeatCount:     u.repeatCount,
+		AfterFirstRp:    u.afterFirstRp,
+		ActiveProgram:   u.activeProgram,
+		ExternalProgram: u.externalProgram,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *Accumulator) Restore(data json.RawMessage) error {
+	s := accSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.sign = s.Sign
+	u.decade = s.Decade
+	u.carry = s.Carry
+	u.carry2 = s.Carry2
+	u.inff1 = s.Inff1
+	u.inff2 = s.Inff2
+	u.repeating = s.Repeating
+	u.repeatCount = s.RepeatCount
+	u.afterFirstRp = s.AfterFirstRp
+	u.activeProgram = s.ActiveProgram
+	u.externalProgram = s.ExternalProgram
+	u.enableInputs()
+	return nil
+}
+
 func (u *Accumulator) AttachTracer(tracer Tracer) {
 	u.tracer = tracer
 	sign := u.terminal("sign")
@@ -530,6 +661,37 @@ func (u *Accumulator) Reset() {
 	u.clearInternal()
 }
 
+// PowerOn puts the accumulator in a random state, as the real machine came up
+// when first switched on.
+func (u *Accumulator) PowerOn(r *rand.Rand) {
+	u.decade = 0
+	u.carry = 0
+	for i := 0; i < 10; i++ {
+		u.decade |= uint64(r.Intn(10)) << uint(4*i)
+		u.carry |= uint64(r.Intn(2)) << uint(4*i)
+	}
+	u.carry2 = 0
+	u.sign = r.Intn(2) == 1
+	// The simulator can't model conflicting programs, so at most one program
+	// flip-flop starts set.  It takes effect on the first add cycle.
+	u.inff1 = 0
+	u.inff2 = 0
+	if r.Intn(2) == 1 {
+		u.inff2 = 1 << uint(r.Intn(12))
+	}
+	u.repeating = u.inff2&0xff0 != 0
+	u.repeatCount = 0
+}
+
+// ClearPrograms resets program flip-flops, as on initial clear.
+func (u *Accumulator) ClearPrograms() {
+	u.inff1 = 0
+	u.inff2 = 0
+	u.repeating = false
+	u.repeatCount = 0
+	u.updateActiveProgram()
+}
+
 // Static connections to other non-accumulator units.
 type StaticWiring interface {
 	Sign() string
@@ -627,6 +789,12 @@ func Interconnect(accumulator [20]*Accumulator, p1 []string, p2 []string) error
 	return nil
 }
 
+// Interconnected returns the accumulators wired to u's left and right
+// interconnect terminals, or nil.
+func (u *Accumulator) Interconnected() (left, right *Accumulator) {
+	return u.left, u.right
+}
+
 func (u *Accumulator) FindJack(jack string) (*Jack, error) {
 	switch {
 	case jack == "α", jack == "a", jack == "alpha":
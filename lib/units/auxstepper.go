@@ -1,6 +1,7 @@
 package units
 
 import (
+	"encoding/json"
 	"fmt"
 	. "github.com/jeredw/eniacsim/lib"
 	"strconv"
@@ -20,14 +21,19 @@ type AuxStepper struct {
 	afterFirstRp    bool
 	stage           int
 	steps           int
+
+	name   string
+	tracer Tracer
 }
 
 func NewAuxStepper(name string, steps int) *AuxStepper {
-	u := &AuxStepper{steps: steps}
-	u.i = NewInput(fmt.Sprintf("%s.i", name), func(*Jack, int) {
+	u := &AuxStepper{steps: steps, name: name}
+	u.i = NewInput(fmt.Sprintf("%s.i", name), func(j *Jack, val int) {
+		u.logPulse(j, val)
 		u.inff1 = true
 	})
-	u.di = NewInput(fmt.Sprintf("%s.di", name), func(*Jack, int) {
+	u.di = NewInput(fmt.Sprintf("%s.di", name), func(j *Jack, val int) {
+		u.logPulse(j, val)
 		// Only count once per 10P (ignore 9Ps and non-digit pulses).
 		if u.waitForNextTenp {
 			return
@@ -38,16 +44,36 @@ func NewAuxStepper(name string, steps int) *AuxStepper {
 		}
 		u.waitForNextTenp = true
 	})
-	u.cdi = NewInput(fmt.Sprintf("%s.cdi", name), func(*Jack, int) {
+	u.cdi = NewInput(fmt.Sprintf("%s.cdi", name), func(j *Jack, val int) {
+		u.logPulse(j, val)
 		u.stage = 0
 	})
 	u.o = make([]*Jack, steps)
 	for i := 0; i < steps; i++ {
-		u.o[i] = NewOutput(fmt.Sprintf("%s.o%d", name, i+1), nil)
+		u.o[i] = NewOutput(fmt.Sprintf("%s.o%d", name, i+1), u.logPulse)
+	}
+	for _, j := range append([]*Jack{u.i, u.di, u.cdi}, u.o...) {
+		j.TraceBits = 1
 	}
 	return u
 }
 
+func (u *AuxStepper) logPulse(j *Jack, val int) {
+	if u.tracer != nil {
+		u.tracer.LogPulse(j.Name, 1, int64(val))
+	}
+}
+
+// AttachTracer connects a trace logger, which sees the stage (1-steps) as
+// name.stage.
+func (u *AuxStepper) AttachTracer(tracer Tracer) {
+	u.tracer = tracer
+	stage := u.name + ".stage"
+	tracer.RegisterValueCallback(func() {
+		tracer.LogValue(stage, 4, int64(u.stage+1))
+	})
+}
+
 func (u *AuxStepper) Reset() {
 	u.inff1 = false
 	u.inff2 = false
@@ -56,6 +82,46 @@ func (u *AuxStepper) Reset() {
 	u.stage = 0
 }
 
+// Stage returns the current stage, starting from 1.
+func (u *AuxStepper) Stage() int {
+	return u.stage + 1
+}
+
+type auxStepperSnapshot struct {
+	Inff1           bool `json:"inff1"`
+	Inff2           bool `json:"inff2"`
+	WaitForNextTenp bool `json:"waitForNextTenp"`
+	AfterFirstRp    bool `json:"afterFirstRp"`
+	Stage           int  `json:"stage"`
+}
+
+// Snapshot returns the dynamic state of the stepper.
+func (u *AuxStepper) Snapshot() json.RawMessage {
+	s := auxStepperSnapshot{
+		Inff1:           u.inff1,
+		Inff2:           u.inff2,
+		WaitForNextTenp: u.waitForNextTenp,
+		AfterFirstRp:    u.afterFirstRp,
+		Stage:           u.stage,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *AuxStepper) Restore(data json.RawMessage) error {
+	s := auxStepperSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.inff1 = s.Inff1
+	u.inff2 = s.Inff2
+	u.waitForNextTenp = s.WaitForNextTenp
+	u.afterFirstRp = s.AfterFirstRp
+	u.stage = s.Stage
+	return nil
+}
+
 func (u *AuxStepper) Clock(p Pulse) {
 	if p&Tenp != 0 {
 		u.waitForNextTenp = false
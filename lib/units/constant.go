@@ -1,6 +1,7 @@
 package units
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -58,7 +59,7 @@ func NewConstant() *Constant {
 }
 
 func (u *Constant) newProgramInput(name string, program int) *Jack {
-	return NewInput(name, func(j *Jack, val int) {
+	jack := NewInput(name, func(j *Jack, val int) {
 		if val == 1 {
 			u.trigger(program)
 			if u.tracer != nil {
@@ -66,14 +67,18 @@ func (u *Constant) newProgramInput(name string, program int) *Jack {
 			}
 		}
 	})
+	jack.TraceBits = 1
+	return jack
 }
 
 func (u *Constant) newOutput(name string, width int) *Jack {
-	return NewOutput(name, func(j *Jack, val int) {
+	jack := NewOutput(name, func(j *Jack, val int) {
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, width, int64(val))
 		}
 	})
+	jack.TraceBits = width
+	return jack
 }
 
 func (u *Constant) AttachTracer(tracer Tracer) {
@@ -92,6 +97,67 @@ func (u *Constant) Stat() string {
 	return s
 }
 
+// Value returns the constant most recently selected for transmission, e.g.
+// "P 0000000042".
+func (u *Constant) Value() string {
+	s := []byte("P ")
+	if u.sign {
+		s[0] = 'M'
+	}
+	for i := 9; i >= 0; i-- {
+		s = append(s, byte('0'+u.digits[i]))
+	}
+	return string(s)
+}
+
+type constantSnapshot struct {
+	CardSign   [8][2]bool `json:"cardSign"`
+	CardDigits [8][10]int `json:"cardDigits"`
+	Sign       bool       `json:"sign"`
+	Digits     [10]int    `json:"digits"`
+	Pos1pp     int        `json:"pos1pp"`
+	Inff1      [30]bool   `json:"inff1"`
+	Inff2      [30]bool   `json:"inff2"`
+	WhichRp    bool       `json:"whichRp"`
+	Sending    int        `json:"sending"`
+}
+
+// Snapshot returns the dynamic state of the constant transmitter, including
+// the card relay registers.
+func (u *Constant) Snapshot() json.RawMessage {
+	s := constantSnapshot{
+		CardSign:   u.cardSign,
+		CardDigits: u.cardDigits,
+		Sign:       u.sign,
+		Digits:     u.digits,
+		Pos1pp:     u.pos1pp,
+		Inff1:      u.inff1,
+		Inff2:      u.inff2,
+		WhichRp:    u.whichrp,
+		Sending:    u.sending,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *Constant) Restore(data json.RawMessage) error {
+	s := constantSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.cardSign = s.CardSign
+	u.cardDigits = s.CardDigits
+	u.sign = s.Sign
+	u.digits = s.Digits
+	u.pos1pp = s.Pos1pp
+	u.inff1 = s.Inff1
+	u.inff2 = s.Inff2
+	u.whichrp = s.WhichRp
+	u.sending = s.Sending
+	return nil
+}
+
 func (u *Constant) Reset() {
 	for i := 0; i < 30; i++ {
 		u.sel[i] = 0
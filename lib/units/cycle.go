@@ -1,6 +1,7 @@
 package units
 
 import (
+	"encoding/json"
 	"fmt"
 	. "github.com/jeredw/eniacsim/lib"
 )
@@ -33,6 +34,7 @@ type Cycle struct {
 	targetAddCycle  int64
 	phase           int
 	stop            bool
+	faulty          bool // true if some units have injected faults
 	tracer          Tracer
 }
 
@@ -43,6 +45,8 @@ type CycleConn struct {
 	StepAndVerifyVM func()        // Cross-validate checkpoint with vm
 	StepAheadVM     func(n int64) // Step ahead up to cycle n with vm
 	SelectiveClear  func() bool   // Clear gate (from initiate unit)
+	Record          func()        // Called at add cycle boundaries when running
+	EndAddCycle     func()        // Called at the end of each add cycle when running
 }
 
 // Clock operating modes
@@ -112,8 +116,46 @@ func (u *Cycle) Stat() string {
 	}
 }
 
+type cycleSnapshot struct {
+	AddCycle   int64 `json:"addCycle"`
+	Phase      int   `json:"phase"`
+	Checkpoint bool  `json:"checkpoint"`
+}
+
+// Snapshot returns the current add cycle and pulse phase.
+func (u *Cycle) Snapshot() json.RawMessage {
+	s := cycleSnapshot{
+		AddCycle:   u.AddCycle,
+		Phase:      u.phase,
+		Checkpoint: u.checkpoint,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads state saved by Snapshot.
+func (u *Cycle) Restore(data json.RawMessage) error {
+	s := cycleSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.AddCycle = s.AddCycle
+	u.phase = s.Phase
+	u.checkpoint = s.Checkpoint
+	return nil
+}
+
+// SetFaulty selects whether units are clocked so as to apply injected faults.
+func (u *Cycle) SetFaulty(faulty bool) {
+	u.faulty = faulty
+}
+
 //go:nosplit
 func (u *Cycle) sendPulse(pulse Pulse) {
+	if u.faulty {
+		u.sendFaultyPulse(pulse)
+		return
+	}
 	uu := u.Io.Units
 	uu.Initiate.Clock(pulse)
 	uu.Mp.Clock(pulse)
@@ -160,6 +202,30 @@ func (u *Cycle) sendPulse(pulse Pulse) {
 	//u.Io.Units.OrderSelector.Clock(pulse)
 }
 
+// sendFaultyPulse is sendPulse for when some units have injected faults.
+func (u *Cycle) sendFaultyPulse(pulse Pulse) {
+	uu := u.Io.Units
+	uu.Initiate.Clock(pulse)
+	uu.Mp.Clock(pulse)
+	uu.Divsr.Clock(pulse)
+	uu.Multiplier.Clock(pulse)
+	uu.Constant.Clock(pulse)
+	for _, f := range uu.Ft {
+		if f.Faulty() {
+			f.ClockFaulty(pulse)
+		} else {
+			f.Clock(pulse)
+		}
+	}
+	for _, a := range uu.Accumulator {
+		if a.Faulty() {
+			a.ClockFaulty(pulse)
+		} else {
+			a.Clock(pulse)
+		}
+	}
+}
+
 func (u *Cycle) StepOnePulse() {
 	if u.tracer != nil {
 		u.tracer.AdvanceTimestep()
@@ -193,16 +259,28 @@ func (u *Cycle) StepOnePulse() {
 	}
 }
 
+// stepPulse steps one pulse, calling Record and EndAddCycle at add cycle
+// boundaries.
+func (u *Cycle) stepPulse() {
+	if u.phase == 0 && u.Io.Record != nil {
+		u.Io.Record()
+	}
+	u.StepOnePulse()
+	if u.phase == 0 && u.Io.EndAddCycle != nil {
+		u.Io.EndAddCycle()
+	}
+}
+
 // Returns true if stopped by debugger
 func (u *Cycle) StepNAddCycles(n int) bool {
 	start := u.AddCycle;
 	u.targetAddCycle = start + int64(n)
 	for u.AddCycle < u.targetAddCycle {
-		u.StepOnePulse()
+		u.stepPulse()
 		if u.stop {
 			// If debugger requested a stop, step to start of next add cycle
 			for u.phase != 0 {
-				u.StepOnePulse()
+				u.stepPulse()
 			}
 			// Probably we'll want to single-step add cycles after a breakpoint
 			u.mode = OneAdd
@@ -219,7 +297,9 @@ func (u *Cycle) StepOneAddCycle() {
 
 func (u *Cycle) Step() {
 	if u.mode == OnePulse {
-		u.StepOnePulse()
+		// Stepping by pulses already stops after each, so the debugger needn't.
+		u.stepPulse()
+		u.stop = false
 	} else if u.mode == OneAdd {
 		u.StepOneAddCycle()
 	}
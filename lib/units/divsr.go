@@ -48,29 +48,35 @@ func NewDivsr() *Divsr {
 }
 
 func (u *Divsr) newProgramInput(program int) *Jack {
-	return NewInput(fmt.Sprintf("d.%di", program+1), func(j *Jack, val int) {
+	jack := NewInput(fmt.Sprintf("d.%di", program+1), func(j *Jack, val int) {
 		u.divargs(program)
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, 1, int64(val))
 		}
 	})
+	jack.TraceBits = 1
+	return jack
 }
 
 func (u *Divsr) newInterlockInput(program int) *Jack {
-	return NewInput(fmt.Sprintf("d.%dl", program+1), func(j *Jack, val int) {
+	jack := NewInput(fmt.Sprintf("d.%dl", program+1), func(j *Jack, val int) {
 		u.interlock()
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, 1, int64(val))
 		}
 	})
+	jack.TraceBits = 1
+	return jack
 }
 
 func (u *Divsr) newOutput(name string, width int) *Jack {
-	return NewOutput(name, func(j *Jack, val int) {
+	jack := NewOutput(name, func(j *Jack, val int) {
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, width, int64(val))
 		}
 	})
+	jack.TraceBits = width
+	return jack
 }
 
 type divsrJson struct {
@@ -91,6 +97,96 @@ func (u *Divsr) State() json.RawMessage {
 	return result
 }
 
+type divsrSnapshot struct {
+	Preff     [8]bool         `json:"preff"`
+	Progff    [8]bool         `json:"progff"`
+	PlaceRing int             `json:"placeRing"`
+	ProgRing  int             `json:"progRing"`
+	Ffs       map[string]bool `json:"ffs"`
+	CurProg   int             `json:"curProg"`
+	Sv        int             `json:"sv"`
+	Su2q      int             `json:"su2q"`
+	Su2s      int             `json:"su2s"`
+	Su3       int             `json:"su3"`
+}
+
+// flipFlops returns the divider's internal flip-flops by name.
+func (u *Divsr) flipFlops() map[string]*bool {
+	return map[string]*bool{
+		"divff": &u.divff, "clrff": &u.clrff, "ilockff": &u.ilockff,
+		"coinff": &u.coinff, "dpγ": &u.dpγ, "nγ": &u.nγ, "psrcff": &u.psrcff,
+		"pringff": &u.pringff, "denomff": &u.denomff, "numrplus": &u.numrplus,
+		"numrmin": &u.numrmin, "qα": &u.qα, "sac": &u.sac, "m2": &u.m2,
+		"m1": &u.m1, "nac": &u.nac, "da": &u.da, "nα": &u.nα, "dα": &u.dα,
+		"dγ": &u.dγ, "npγ": &u.npγ, "p2": &u.p2, "p1": &u.p1, "sα": &u.sα,
+		"ds": &u.ds, "nβ": &u.nβ, "dβ": &u.dβ, "ans1": &u.ans1,
+		"ans2": &u.ans2, "ans3": &u.ans3, "ans4": &u.ans4,
+	}
+}
+
+// Snapshot returns the dynamic state of the divider/square rooter.
+func (u *Divsr) Snapshot() json.RawMessage {
+	s := divsrSnapshot{
+		Preff:     u.preff,
+		Progff:    u.progff,
+		PlaceRing: u.placering,
+		ProgRing:  u.progring,
+		Ffs:       make(map[string]bool),
+		CurProg:   u.curprog,
+		Sv:        u.sv,
+		Su2q:      u.su2q,
+		Su2s:      u.su2s,
+		Su3:       u.su3,
+	}
+	for name, ff := range u.flipFlops() {
+		s.Ffs[name] = *ff
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *Divsr) Restore(data json.RawMessage) error {
+	s := divsrSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.preff = s.Preff
+	u.progff = s.Progff
+	u.placering = s.PlaceRing
+	u.progring = s.ProgRing
+	for name, ff := range u.flipFlops() {
+		*ff = s.Ffs[name]
+	}
+	u.curprog = s.CurProg
+	u.sv = s.Sv
+	u.su2q = s.Su2q
+	u.su2s = s.Su2s
+	u.su3 = s.Su3
+	u.setExternalPrograms()
+	return nil
+}
+
+// Busy returns true while a division or square root is in progress.
+func (u *Divsr) Busy() bool {
+	for _, ff := range u.progff {
+		if ff {
+			return true
+		}
+	}
+	return false
+}
+
+// Program returns the program in progress (1-8), or 0 if none is.
+func (u *Divsr) Program() int {
+	for i, ff := range u.progff {
+		if ff {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 func (u *Divsr) Stat() string {
 	s := fmt.Sprintf("%d %d ", u.placering, u.progring)
 	for i := range u.progff {
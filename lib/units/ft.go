@@ -3,6 +3,7 @@ package units
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strconv"
 
 	. "github.com/jeredw/eniacsim/lib"
@@ -33,12 +34,26 @@ type Ft struct {
 	whichrp          bool
 	px4119           bool
 	prog             int
+
+	readFaults []readFault
+	tracer     Tracer
+}
+
+// readFault makes a switch read as value while the unit is clocked, as if its
+// contacts were faulty.  The switch itself keeps its setting.
+type readFault struct {
+	data  *int
+	value int
+	saved int // Setting while clocked
 }
 
 func NewFt(unit int) *Ft {
 	u := &Ft{unit: unit}
 	unitDot := fmt.Sprintf("f%d.", unit+1)
 	u.jack[0] = NewInput(unitDot+"arg", func(j *Jack, val int) {
+		if u.tracer != nil {
+			u.tracer.LogPulse(j.Name, 2, int64(val))
+		}
 		if u.gateh42 {
 			if val&0x01 != 0 {
 				u.arg++
@@ -48,22 +63,75 @@ func NewFt(unit int) *Ft {
 			}
 		}
 	})
-	u.jack[1] = NewOutput(unitDot+"A", nil)
-	u.jack[2] = NewOutput(unitDot+"B", nil)
-	u.jack[3] = NewOutput(unitDot+"NC", nil)
-	u.jack[4] = NewOutput(unitDot+"C", nil)
+	u.jack[0].TraceBits = 2
+	u.jack[1] = u.newOutput(unitDot+"A", 11)
+	u.jack[2] = u.newOutput(unitDot+"B", 11)
+	u.jack[3] = u.newOutput(unitDot+"NC", 1)
+	u.jack[4] = u.newOutput(unitDot+"C", 1)
 	programInput := func(prog int) JackHandler {
-		return func(*Jack, int) {
+		return func(j *Jack, val int) {
 			u.trigger(prog)
+			if u.tracer != nil {
+				u.tracer.LogPulse(j.Name, 1, int64(val))
+			}
 		}
 	}
 	for i := 0; i < 11; i++ {
 		u.jack[5+2*i] = NewInput(fmt.Sprintf("%s%di", unitDot, i+1), programInput(i))
-		u.jack[5+2*i+1] = NewOutput(fmt.Sprintf("%s%do", unitDot, i+1), nil)
+		u.jack[5+2*i].TraceBits = 1
+		u.jack[5+2*i+1] = u.newOutput(fmt.Sprintf("%s%do", unitDot, i+1), 1)
 	}
 	return u
 }
 
+func (u *Ft) newOutput(name string, width int) *Jack {
+	jack := NewOutput(name, func(j *Jack, val int) {
+		if u.tracer != nil {
+			u.tracer.LogPulse(j.Name, width, int64(val))
+		}
+	})
+	jack.TraceBits = width
+	return jack
+}
+
+// AttachTracer connects a trace logger.
+func (u *Ft) AttachTracer(tracer Tracer) {
+	u.tracer = tracer
+	unitDot := fmt.Sprintf("f%d.", u.unit+1)
+	arg, ring := unitDot+"argument", unitDot+"ring"
+	add, subtr := unitDot+"add", unitDot+"subtr"
+	tracer.RegisterValueCallback(func() {
+		tracer.LogValue(arg, 7, int64(u.arg))
+		tracer.LogValue(ring, 4, int64(u.ring))
+		tracer.LogValue(add, 1, BoolToInt64(u.add))
+		tracer.LogValue(subtr, 1, BoolToInt64(u.subtr))
+	})
+}
+
+// Busy returns true while any program is in progress.
+func (u *Ft) Busy() bool {
+	if u.ring != 0 {
+		return true
+	}
+	for _, ff := range u.inff2 {
+		if ff {
+			return true
+		}
+	}
+	return false
+}
+
+// Program returns the program whose lookup is in progress (1-11), or 0 if
+// none is.
+func (u *Ft) Program() int {
+	for i, ff := range u.inff2 {
+		if ff {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 func (u *Ft) Stat() string {
 	s := ""
 	for i := range u.inff2 {
@@ -116,6 +184,59 @@ func (u *Ft) State() json.RawMessage {
 	return result
 }
 
+type ftSnapshot struct {
+	Inff1    [11]bool `json:"inff1"`
+	Inff2    [11]bool `json:"inff2"`
+	Arg      int      `json:"arg"`
+	Ring     int      `json:"ring"`
+	Add      bool     `json:"add"`
+	Subtract bool     `json:"subtract"`
+	ArgSetup bool     `json:"argSetup"`
+	GateH42  bool     `json:"gateH42"`
+	GateE42  bool     `json:"gateE42"`
+	WhichRp  bool     `json:"whichRp"`
+	Prog     int      `json:"prog"`
+}
+
+// Snapshot returns the dynamic state of the function table.
+func (u *Ft) Snapshot() json.RawMessage {
+	s := ftSnapshot{
+		Inff1:    u.inff1,
+		Inff2:    u.inff2,
+		Arg:      u.arg,
+		Ring:     u.ring,
+		Add:      u.add,
+		Subtract: u.subtr,
+		ArgSetup: u.argsetup,
+		GateH42:  u.gateh42,
+		GateE42:  u.gatee42,
+		WhichRp:  u.whichrp,
+		Prog:     u.prog,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *Ft) Restore(data json.RawMessage) error {
+	s := ftSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.inff1 = s.Inff1
+	u.inff2 = s.Inff2
+	u.arg = s.Arg
+	u.ring = s.Ring
+	u.add = s.Add
+	u.subtr = s.Subtract
+	u.argsetup = s.ArgSetup
+	u.gateh42 = s.GateH42
+	u.gatee42 = s.GateE42
+	u.whichrp = s.WhichRp
+	u.prog = s.Prog
+	return nil
+}
+
 func (u *Ft) Reset() {
 	for i := range u.inff1 {
 		u.inff1[i] = false
@@ -149,6 +270,51 @@ func (u *Ft) Reset() {
 	u.px4119 = false
 }
 
+// PowerOn puts the function table's program flip-flops, argument counter and
+// program ring in a random state, as the real machine came up when first
+// switched on.  As for accumulators, at most one program flip-flop starts
+// set, and the ring is only away from its first stage for that program.
+func (u *Ft) PowerOn(r *rand.Rand) {
+	for i := range u.inff2 {
+		u.inff1[i] = false
+		u.inff2[i] = false
+	}
+	u.arg = r.Intn(100)
+	u.ring = 0
+	if r.Intn(2) == 1 {
+		u.prog = r.Intn(11)
+		u.inff2[u.prog] = true
+		u.ring = r.Intn(13)
+	}
+	u.gateh42 = u.ring == 1
+	u.gatee42 = u.ring == 2
+	u.argsetup = u.ring > 2
+	// The add and subtract flip-flops are set from the stage 0 op switch, which
+	// no configuration has set yet, so either may be.
+	u.add = false
+	u.subtr = false
+	if u.ring > 3 {
+		u.add = r.Intn(2) == 1
+		u.subtr = !u.add
+	}
+}
+
+// ClearPrograms resets program flip-flops and the program ring, as on initial
+// clear.
+func (u *Ft) ClearPrograms() {
+	for i := range u.inff2 {
+		u.inff1[i] = false
+		u.inff2[i] = false
+	}
+	u.arg = 0
+	u.ring = 0
+	u.add = false
+	u.subtr = false
+	u.argsetup = false
+	u.gateh42 = false
+	u.gatee42 = false
+}
+
 func (u *Ft) FindJack(jack string) (*Jack, error) {
 	switch jack {
 	case "arg", "ARG":
@@ -223,7 +389,7 @@ func (u *Ft) FindSwitch(name string) (Switch, error) {
 			}
 			return &IntSwitch{name, &u.cons[4*offset+digit-1], consSettings()}, nil
 		case 's', 'S':
-			if !(digit >= 4 && digit <= 10) {
+			if !(digit >= 5 && digit <= 10) {
 				return nil, fmt.Errorf("invalid switch %s", name)
 			}
 			return &IntSwitch{name, &u.sub[6*offset+digit-5], subSettings()}, nil
@@ -514,6 +680,52 @@ func (u *Ft) subtrlookup(c Pulse) {
 	}
 }
 
+// AddReadFault makes the value switch name read as value when the function
+// table is looked up, without changing its setting.
+func (u *Ft) AddReadFault(name, value string) error {
+	sw, err := u.FindSwitch(name)
+	if err != nil {
+		return err
+	}
+	is, ok := sw.(*IntSwitch)
+	if !ok {
+		return fmt.Errorf("switch %s cannot read wrong", name)
+	}
+	setting := *is.Data
+	err = is.Set(value)
+	fault := readFault{data: is.Data, value: *is.Data}
+	*is.Data = setting
+	if err != nil {
+		return err
+	}
+	u.readFaults = append(u.readFaults, fault)
+	return nil
+}
+
+// ClearFaults removes any faults added by AddReadFault.
+func (u *Ft) ClearFaults() {
+	u.readFaults = nil
+}
+
+// Faulty returns true if faults have been injected.
+func (u *Ft) Faulty() bool {
+	return len(u.readFaults) != 0
+}
+
+// ClockFaulty is Clock for a function table with injected faults.
+func (u *Ft) ClockFaulty(p Pulse) {
+	for i := range u.readFaults {
+		f := &u.readFaults[i]
+		f.saved = *f.data
+		*f.data = f.value
+	}
+	u.Clock(p)
+	for i := len(u.readFaults) - 1; i >= 0; i-- {
+		f := &u.readFaults[i]
+		*f.data = f.saved
+	}
+}
+
 func (u *Ft) Clock(p Pulse) {
 	if u.px4119 {
 		if p&Cpp != 0 {
@@ -587,7 +799,8 @@ func (u *Ft) Clock(p Pulse) {
 				u.subtr = true
 			}
 		default: // Stages 1-9
-			if u.rptsw[u.prog] == u.ring-4 {
+			// A ring left past the repeat stage at power on also ends here.
+			if u.rptsw[u.prog] <= u.ring-4 {
 				u.jack[u.prog*2+6].Transmit(1)
 				u.arg = 0
 				u.add = false
@@ -625,6 +838,39 @@ func (u *Ft) trigger(input int) {
 	u.inff1[input] = true
 }
 
+// Arg returns the argument counter.
+func (u *Ft) Arg() int {
+	return u.arg
+}
+
+// Output returns the value looked up for the current argument on side 'A' or
+// 'B' as a sign and six digits, e.g. "M 012345".  Digits supplied by the
+// constant switches are not included.
+func (u *Ft) Output(side byte) string {
+	arg := u.arg
+	if !(arg >= 0 && arg < 104) {
+		arg = 0
+	}
+	row := u.tab[arg]
+	var minus bool
+	var digits []int
+	if side == 'A' {
+		minus = u.pm1 == 1 || u.pm1 == 2 && row[0] == 1
+		digits = row[1:7]
+	} else {
+		minus = u.pm2 == 1 || u.pm2 == 2 && row[13] == 1
+		digits = row[7:13]
+	}
+	s := []byte("P ")
+	if minus {
+		s[0] = 'M'
+	}
+	for _, d := range digits {
+		s = append(s, byte('0'+d))
+	}
+	return string(s)
+}
+
 func (u *Ft) GetDigit(row, digit int) int {
 	return u.tab[row][digit]
 }
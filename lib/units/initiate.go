@@ -2,8 +2,11 @@ package units
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	. "github.com/jeredw/eniacsim/lib"
+	"io"
+	"os"
 	"strconv"
 )
 
@@ -20,13 +23,22 @@ type Initiate struct {
 	clrff                           [6]bool
 
 	cardScanner *bufio.Scanner
+	readCards   []string // Cards kept after reading from cardScanner
+	firstCard   int      // Position in the deck of readCards[0]
+	keepCards   bool
+	cardsRead   int
 	punchWriter *bufio.Writer
+	replaying   bool
+
+	tracer Tracer
 }
 
 // InitiateConn defines connections needed for the unit
 type InitiateConn struct {
 	Ppunch     chan string
+	Output     io.Writer // Where cards are printed without a punch file
 	Units      []Cleared
+	Programs   []ProgramCleared
 	ReadCard   func(string)
 	Print      func() string
 
@@ -35,24 +47,31 @@ type InitiateConn struct {
 }
 
 func NewInitiate(io InitiateConn) *Initiate {
+	if io.Output == nil {
+		io.Output = os.Stdout
+	}
 	u := &Initiate{Io: io}
 	clearInput := func(prog int) JackHandler {
-		return func(*Jack, int) {
+		return func(j *Jack, val int) {
+			u.logPulse(j, val)
 			u.clrff[prog] = true
 		}
 	}
 	for i := 0; i < 6; i++ {
 		u.jack[2*i] = NewInput(fmt.Sprintf("i.Ci%d", i+1), clearInput(i))
-		u.jack[2*i+1] = NewOutput(fmt.Sprintf("i.Co%d", i+1), nil)
+		u.jack[2*i+1] = NewOutput(fmt.Sprintf("i.Co%d", i+1), u.logPulse)
 	}
-	u.jack[12] = NewInput("i.Rl", func(*Jack, int) {
+	u.jack[12] = NewInput("i.Rl", func(j *Jack, val int) {
+		u.logPulse(j, val)
 		u.rdilock = true
 	})
-	u.jack[13] = NewInput("i.Ri", func(*Jack, int) {
+	u.jack[13] = NewInput("i.Ri", func(j *Jack, val int) {
+		u.logPulse(j, val)
 		u.rdff = true
 	})
-	u.jack[14] = NewOutput("i.Ro", nil)
-	u.jack[15] = NewInput("i.Pi", func(*Jack, int) {
+	u.jack[14] = NewOutput("i.Ro", u.logPulse)
+	u.jack[15] = NewInput("i.Pi", func(j *Jack, val int) {
+		u.logPulse(j, val)
 		if !u.printPhase1 {
 			u.prff = true
 			if !u.printPhase2 {
@@ -61,23 +80,135 @@ func NewInitiate(io InitiateConn) *Initiate {
 			}
 		}
 	})
-	u.jack[16] = NewOutput("i.Po", nil)
-	u.jack[17] = NewOutput("i.Io", nil)
+	u.jack[16] = NewOutput("i.Po", u.logPulse)
+	u.jack[17] = NewOutput("i.Io", u.logPulse)
+	for _, j := range u.jack {
+		j.TraceBits = 1
+	}
 	return u
 }
 
+func (u *Initiate) logPulse(j *Jack, val int) {
+	if u.tracer != nil {
+		u.tracer.LogPulse(j.Name, 1, int64(val))
+	}
+}
+
+// AttachTracer connects a trace logger.  Besides the reader and printer flip
+// flops, i.read and i.print pulse as each card is read and printed.
+func (u *Initiate) AttachTracer(tracer Tracer) {
+	u.tracer = tracer
+	tracer.RegisterValueCallback(func() {
+		tracer.LogValue("i.rdff", 1, BoolToInt64(u.rdff))
+		tracer.LogValue("i.rdilock", 1, BoolToInt64(u.rdilock))
+		tracer.LogValue("i.rdsync", 1, BoolToInt64(u.rdsync))
+		tracer.LogValue("i.rdfinish", 1, BoolToInt64(u.rdfinish))
+		tracer.LogValue("i.prff", 1, BoolToInt64(u.prff))
+		tracer.LogValue("i.printPhase1", 1, BoolToInt64(u.printPhase1))
+		tracer.LogValue("i.printPhase2", 1, BoolToInt64(u.printPhase2))
+	})
+}
+
+// TracedPulses returns the names of the 1 bit pulses logged other than those
+// on jacks.
+func (u *Initiate) TracedPulses() []string {
+	return []string{"i.read", "i.print"}
+}
+
 func (u *Initiate) SelectiveClear() bool {
 	return u.clrff[0] || u.clrff[1] || u.clrff[2] || u.clrff[3] || u.clrff[4] || u.clrff[5]
 }
 
 func (u *Initiate) SetCardScanner(cardScanner *bufio.Scanner) {
 	u.cardScanner = cardScanner
+	u.readCards = nil
+	u.firstCard = 0
+	u.cardsRead = 0
+}
+
+// KeepCards starts or stops keeping cards as they are read, so that Restore
+// can move the reader back to them.  Stopping drops any cards kept so far.
+func (u *Initiate) KeepCards(keep bool) {
+	u.keepCards = keep
+	if !keep {
+		u.DropCardsBefore(u.cardsRead)
+	}
+}
+
+// DropCardsBefore forgets kept cards before position n in the deck, which
+// Restore will no longer need.
+func (u *Initiate) DropCardsBefore(n int) {
+	if n > u.cardsRead {
+		n = u.cardsRead
+	}
+	if n <= u.firstCard {
+		return
+	}
+	if n-u.firstCard >= len(u.readCards) {
+		u.readCards = nil
+	} else {
+		u.readCards = append([]string(nil), u.readCards[n-u.firstCard:]...)
+	}
+	u.firstCard = n
+}
+
+// CardsRead returns how many cards have been read from the current deck.
+func (u *Initiate) CardsRead() int {
+	return u.cardsRead
+}
+
+// KeptCards returns how many cards are kept for Restore.
+func (u *Initiate) KeptCards() int {
+	return len(u.readCards)
+}
+
+// SetReplaying suppresses printing while the machine replays add cycles it
+// has already run once.
+func (u *Initiate) SetReplaying(replaying bool) {
+	u.replaying = replaying
+}
+
+// nextCard returns the next card in the deck, if there is one.  Cards are
+// kept once read while KeepCards is on so that the reader can be moved back
+// by Restore.
+func (u *Initiate) nextCard() (string, bool) {
+	if i := u.cardsRead - u.firstCard; i < len(u.readCards) {
+		u.cardsRead++
+		return u.readCards[i], true
+	}
+	if u.cardScanner == nil {
+		return "", false
+	}
+	if !u.cardScanner.Scan() {
+		u.cardScanner = nil
+		return "", false
+	}
+	card := u.cardScanner.Text()
+	if u.keepCards {
+		u.readCards = append(u.readCards, card)
+	}
+	u.cardsRead++
+	if !u.keepCards {
+		u.firstCard = u.cardsRead
+	}
+	return card, true
 }
 
 func (u *Initiate) SetPunchWriter(punchWriter *bufio.Writer) {
 	u.punchWriter = punchWriter
 }
 
+// WaitingForReader returns true if a card has been requested but not yet read.
+func (u *Initiate) WaitingForReader() bool {
+	return u.rdff && !u.rdfinish
+}
+
+// WaitingForPrinter returns true if a print has been requested but not yet
+// finished.
+func (u *Initiate) WaitingForPrinter() bool {
+	return u.printPhase1 || (u.printPhase2 && u.prff)
+}
+
 func (u *Initiate) Stat() string {
 	s := ""
 	for _, f := range u.clrff {
@@ -93,6 +224,75 @@ func (u *Initiate) Stat() string {
 	return s
 }
 
+type initiateSnapshot struct {
+	Gate66       int     `json:"gate66"`
+	Gate69       int     `json:"gate69"`
+	Prff         bool    `json:"prff"`
+	PrintPhase1  bool    `json:"printPhase1"`
+	PrintPhase2  bool    `json:"printPhase2"`
+	LastPrint    int64   `json:"lastPrint"`
+	Rdff         bool    `json:"rdff"`
+	Rdilock      bool    `json:"rdilock"`
+	Rdsync       bool    `json:"rdsync"`
+	Rdfinish     bool    `json:"rdfinish"`
+	LastCardRead int64   `json:"lastCardRead"`
+	Clrff        [6]bool `json:"clrff"`
+	CardsRead    int     `json:"cardsRead"`
+}
+
+// Snapshot returns the dynamic state of the initiate unit, including how many
+// cards have been read from the current deck.
+func (u *Initiate) Snapshot() json.RawMessage {
+	s := initiateSnapshot{
+		Gate66:       u.gate66,
+		Gate69:       u.gate69,
+		Prff:         u.prff,
+		PrintPhase1:  u.printPhase1,
+		PrintPhase2:  u.printPhase2,
+		LastPrint:    u.lastPrint,
+		Rdff:         u.rdff,
+		Rdilock:      u.rdilock,
+		Rdsync:       u.rdsync,
+		Rdfinish:     u.rdfinish,
+		LastCardRead: u.lastCardRead,
+		Clrff:        u.clrff,
+		CardsRead:    u.cardsRead,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.  If a deck is loaded, the
+// reader is moved to the saved position, skipping cards if necessary.  It
+// can only move back to cards kept by KeepCards.
+func (u *Initiate) Restore(data json.RawMessage) error {
+	s := initiateSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.gate66 = s.Gate66
+	u.gate69 = s.Gate69
+	u.prff = s.Prff
+	u.printPhase1 = s.PrintPhase1
+	u.printPhase2 = s.PrintPhase2
+	u.lastPrint = s.LastPrint
+	u.rdff = s.Rdff
+	u.rdilock = s.Rdilock
+	u.rdsync = s.Rdsync
+	u.rdfinish = s.Rdfinish
+	u.lastCardRead = s.LastCardRead
+	u.clrff = s.Clrff
+	if s.CardsRead >= u.firstCard && s.CardsRead <= u.firstCard+len(u.readCards) {
+		u.cardsRead = s.CardsRead
+	}
+	for u.cardsRead < s.CardsRead {
+		if _, ok := u.nextCard(); !ok {
+			break
+		}
+	}
+	return nil
+}
+
 func (u *Initiate) Reset() {
 	u.gate66 = 0
 	u.gate69 = 0
@@ -184,15 +384,13 @@ func (u *Initiate) Clock(cyc Pulse) {
 		}
 		sinceCardRead := u.Io.AddCycle() - u.lastCardRead
 		if u.rdff && (stepping || sinceCardRead > MsToAddCycles(375)) {
-			if u.cardScanner != nil {
-				if u.cardScanner.Scan() {
-					card := u.cardScanner.Text()
-					u.Io.ReadCard(card)
-					u.lastCardRead = u.Io.AddCycle()
-					u.rdfinish = true
-				} else {
-					u.cardScanner = nil
+			if card, ok := u.nextCard(); ok {
+				u.Io.ReadCard(card)
+				if u.tracer != nil {
+					u.tracer.LogPulse("i.read", 1, 1)
 				}
+				u.lastCardRead = u.Io.AddCycle()
+				u.rdfinish = true
 			}
 		}
 		if u.rdfinish && u.rdilock {
@@ -201,14 +399,19 @@ func (u *Initiate) Clock(cyc Pulse) {
 		sincePrint := u.Io.AddCycle() - u.lastPrint
 		if u.printPhase1 && (stepping || sincePrint > MsToAddCycles(150)) {
 			s := u.Io.Print()
-			if u.punchWriter != nil {
-				u.punchWriter.WriteString(s)
-				u.punchWriter.WriteByte('\n')
-			} else {
-				fmt.Println(s)
+			if u.tracer != nil {
+				u.tracer.LogPulse("i.print", 1, 1)
 			}
-			if u.Io.Ppunch != nil {
-				u.Io.Ppunch <- s
+			if !u.replaying {
+				if u.punchWriter != nil {
+					u.punchWriter.WriteString(s)
+					u.punchWriter.WriteByte('\n')
+				} else {
+					fmt.Fprintln(u.Io.Output, s)
+				}
+				if u.Io.Ppunch != nil {
+					u.Io.Ppunch <- s
+				}
 			}
 			u.jack[16].Transmit(1)
 			u.lastPrint = u.Io.AddCycle()
@@ -230,6 +433,9 @@ func (u *Initiate) PushClearButton() {
 	for _, c := range u.Io.Units {
 		c.Clear()
 	}
+	for _, p := range u.Io.Programs {
+		p.ClearPrograms()
+	}
 }
 
 func (u *Initiate) PushReadButton() {
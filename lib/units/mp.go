@@ -3,6 +3,7 @@ package units
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strings"
 	"unicode"
 
@@ -15,6 +16,7 @@ type Mp struct {
 	decade        [20]mpDecade  // Decade counters (#20 down to #1)
 	associator    [8]byte       // Stepper to decade associations
 	unplugDecades bool          // Disassociate all decades from steppers
+	tracer        Tracer
 }
 
 type mpStepper struct {
@@ -59,7 +61,8 @@ var associatorResets [8]byte = [8]byte{'A', 'B', 'C', 'D', 'F', 'G', 'H', 'J'}
 func NewMp() *Mp {
 	u := &Mp{associator: associatorResets}
 	decadeIncrement := func(d int) JackHandler {
-		return func(*Jack, int) {
+		return func(j *Jack, val int) {
+			u.logPulse(j, val)
 			// Increment decade and carry into any associated, more significant decades.
 			ds := u.getAssociatedDecadesForDecade(d)
 			for i := d; i >= 0; i-- {
@@ -74,20 +77,24 @@ func NewMp() *Mp {
 		}
 	}
 	for i := 0; i < 20; i++ {
-		u.decade[i].di = NewInput(fmt.Sprintf("p.%ddi", i+1), decadeIncrement(i))
+		u.decade[i].di = NewInput(fmt.Sprintf("p.%ddi", 20-i), decadeIncrement(i))
+		u.decade[i].di.TraceBits = 1
 	}
 	stepperIncrement := func(s int) JackHandler {
-		return func(*Jack, int) {
+		return func(j *Jack, val int) {
+			u.logPulse(j, val)
 			u.stepper[s].increment()
 		}
 	}
 	stepperInput := func(s int) JackHandler {
-		return func(*Jack, int) {
+		return func(j *Jack, val int) {
+			u.logPulse(j, val)
 			u.stepper[s].inff = 1
 		}
 	}
 	stepperClear := func(s int) JackHandler {
-		return func(*Jack, int) {
+		return func(j *Jack, val int) {
+			u.logPulse(j, val)
 			u.stepper[s].stage = 0
 		}
 	}
@@ -97,12 +104,44 @@ func NewMp() *Mp {
 		u.stepper[i].i = NewInput(fmt.Sprintf("p.%ci", stepper), stepperInput(i))
 		u.stepper[i].cdi = NewInput(fmt.Sprintf("p.%ccdi", stepper), stepperClear(i))
 		for j := 0; j < 6; j++ {
-			u.stepper[i].o[j] = NewOutput(fmt.Sprintf("p.%c%do", stepper, j+1), nil)
+			u.stepper[i].o[j] = NewOutput(fmt.Sprintf("p.%c%do", stepper, j+1), u.logPulse)
+		}
+		s := &u.stepper[i]
+		for _, j := range append([]*Jack{s.di, s.i, s.cdi}, s.o[:]...) {
+			j.TraceBits = 1
 		}
 	}
 	return u
 }
 
+func (u *Mp) logPulse(j *Jack, val int) {
+	if u.tracer != nil {
+		u.tracer.LogPulse(j.Name, 1, int64(val))
+	}
+}
+
+// AttachTracer connects a trace logger, which sees stepper stages (1-6) as
+// p.A-p.K and decade values as p.d20-p.d1.
+func (u *Mp) AttachTracer(tracer Tracer) {
+	u.tracer = tracer
+	var stages [10]string
+	for i := range u.stepper {
+		stages[i] = fmt.Sprintf("p.%c", stepperIndexToName(i))
+	}
+	var decades [20]string
+	for i := range u.decade {
+		decades[i] = fmt.Sprintf("p.d%d", 20-i)
+	}
+	tracer.RegisterValueCallback(func() {
+		for i := range u.stepper {
+			tracer.LogValue(stages[i], 3, int64(u.stepper[i].stage+1))
+		}
+		for i := range u.decade {
+			tracer.LogValue(decades[i], 4, int64(u.decade[i].val))
+		}
+	})
+}
+
 func (u *Mp) PrinterDecades() string {
 	s := ""
 	// Printer is wired to decades #14-18 which are at indices 2-6
@@ -153,6 +192,48 @@ func (u *Mp) State() json.RawMessage {
 	return result
 }
 
+type mpSnapshot struct {
+	Stage           [10]int  `json:"stage"`
+	Inff            [10]int  `json:"inff"`
+	WaitForNextTenp [10]bool `json:"waitForNextTenp"`
+	Decade          [20]int  `json:"decade"`
+	Carry           [20]bool `json:"carry"`
+}
+
+// Snapshot returns the dynamic state of steppers and decades.
+func (u *Mp) Snapshot() json.RawMessage {
+	s := mpSnapshot{}
+	for i := range u.stepper {
+		s.Stage[i] = u.stepper[i].stage
+		s.Inff[i] = u.stepper[i].inff
+		s.WaitForNextTenp[i] = u.stepper[i].waitForNextTenp
+	}
+	for i := range u.decade {
+		s.Decade[i] = u.decade[i].val
+		s.Carry[i] = u.decade[i].carry
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *Mp) Restore(data json.RawMessage) error {
+	s := mpSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for i := range u.stepper {
+		u.stepper[i].stage = s.Stage[i]
+		u.stepper[i].inff = s.Inff[i]
+		u.stepper[i].waitForNextTenp = s.WaitForNextTenp[i]
+	}
+	for i := range u.decade {
+		u.decade[i].val = s.Decade[i]
+		u.decade[i].carry = s.Carry[i]
+	}
+	return nil
+}
+
 func (u *Mp) Reset() {
 	for i := range u.decade {
 		for j := range u.decade[i].limit {
@@ -181,6 +262,19 @@ func (u *Mp) Clear() {
 	}
 }
 
+// PowerOn puts decades and steppers in a random state, as the real machine
+// came up when first switched on.
+func (u *Mp) PowerOn(r *rand.Rand) {
+	for i := range u.decade {
+		u.decade[i].val = r.Intn(10)
+		u.decade[i].carry = false
+	}
+	for i := range u.stepper {
+		u.stepper[i].stage = r.Intn(6)
+		u.stepper[i].inff = r.Intn(2)
+	}
+}
+
 func (u *Mp) FindJack(jack string) (*Jack, error) {
 	if len(jack) == 0 {
 		return nil, fmt.Errorf("invalid jack")
@@ -460,6 +554,25 @@ func (u *Mp) Clock(cyc Pulse) {
 	}
 }
 
+// Stage returns the stage (1-6) of the stepper named by letter, or 0 if
+// there is no such stepper.
+func (u *Mp) Stage(stepper byte) int {
+	i := stepperNameToIndex(stepper)
+	if i < 0 {
+		return 0
+	}
+	return u.stepper[i].stage + 1
+}
+
+// Decade returns the value of decade counter n (1-20), or -1 if there is no
+// such counter.
+func (u *Mp) Decade(n int) int {
+	if !(n >= 1 && n <= 20) {
+		return -1
+	}
+	return u.decade[20-n].val
+}
+
 func stepperNameToIndex(s byte) int {
 	return strings.IndexByte("ABCDEFGHJK", s)
 }
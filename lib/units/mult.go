@@ -67,20 +67,24 @@ func NewMultiplier() *Multiplier {
 }
 
 func (u *Multiplier) newProgramInput(program int) *Jack {
-	return NewInput(fmt.Sprintf("m.%di", program+1), func(j *Jack, val int) {
+	jack := NewInput(fmt.Sprintf("m.%di", program+1), func(j *Jack, val int) {
 		u.multargs(program)
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, 1, int64(val))
 		}
 	})
+	jack.TraceBits = 1
+	return jack
 }
 
 func (u *Multiplier) newOutput(name string, width int) *Jack {
-	return NewOutput(name, func(j *Jack, val int) {
+	jack := NewOutput(name, func(j *Jack, val int) {
 		if u.tracer != nil {
 			u.tracer.LogPulse(j.Name, width, int64(val))
 		}
 	})
+	jack.TraceBits = width
+	return jack
 }
 
 func (u *Multiplier) AttachTracer(tracer Tracer) {
@@ -96,6 +100,16 @@ func (u *Multiplier) AttachTracer(tracer Tracer) {
 	})
 }
 
+// Busy returns true while a multiplication is in progress.
+func (u *Multiplier) Busy() bool {
+	return u.activeProgram() != -1
+}
+
+// Program returns the program in progress (1-24), or 0 if none is.
+func (u *Multiplier) Program() int {
+	return u.activeProgram() + 1
+}
+
 func (u *Multiplier) Stat() string {
 	s := fmt.Sprintf("%d ", u.stage)
 	for i := range u.multff {
@@ -118,6 +132,24 @@ func (u *Multiplier) Stat() string {
 	return s
 }
 
+// Ier returns the multiplier as read at the start of the current or last
+// multiplication, e.g. "P 0000000042".
+func (u *Multiplier) Ier() string {
+	if u.ier == "" {
+		return "P 0000000000"
+	}
+	return u.ier
+}
+
+// Icand returns the multiplicand as read at the start of the current or last
+// multiplication.
+func (u *Multiplier) Icand() string {
+	if u.icand == "" {
+		return "P 0000000000"
+	}
+	return u.icand
+}
+
 type multJson struct {
 	Reset1  bool     `json:"reset1"`
 	Reset3  bool     `json:"reset3"`
@@ -136,6 +168,59 @@ func (u *Multiplier) State() json.RawMessage {
 	return result
 }
 
+type multSnapshot struct {
+	Stage    int      `json:"stage"`
+	Program  [24]bool `json:"program"`
+	Reset1   bool     `json:"reset1"`
+	Reset3   bool     `json:"reset3"`
+	Buffer61 bool     `json:"buffer61"`
+	F44      bool     `json:"f44"`
+	Ier      string   `json:"ier"`
+	Icand    string   `json:"icand"`
+	Sigfig   int      `json:"sigfig"`
+	Multl    bool     `json:"multl"`
+	Multr    bool     `json:"multr"`
+}
+
+// Snapshot returns the dynamic state of the multiplier.
+func (u *Multiplier) Snapshot() json.RawMessage {
+	s := multSnapshot{
+		Stage:    u.stage,
+		Program:  u.multff,
+		Reset1:   u.reset1ff,
+		Reset3:   u.reset3ff,
+		Buffer61: u.buffer61,
+		F44:      u.f44,
+		Ier:      u.ier,
+		Icand:    u.icand,
+		Sigfig:   u.sigfig,
+		Multl:    u.multl,
+		Multr:    u.multr,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *Multiplier) Restore(data json.RawMessage) error {
+	s := multSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.stage = s.Stage
+	u.multff = s.Program
+	u.reset1ff = s.Reset1
+	u.reset3ff = s.Reset3
+	u.buffer61 = s.Buffer61
+	u.f44 = s.F44
+	u.ier = s.Ier
+	u.icand = s.Icand
+	u.sigfig = s.Sigfig
+	u.multl = s.Multl
+	u.multr = s.Multr
+	return nil
+}
+
 func (u *Multiplier) Reset() {
 	for i := 0; i < 24; i++ {
 		u.multff[i] = false
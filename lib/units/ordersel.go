@@ -1,6 +1,7 @@
 package units
 
 import (
+	"encoding/json"
 	"fmt"
 	. "github.com/jeredw/eniacsim/lib"
 )
@@ -35,6 +36,8 @@ type OrderSelector struct {
 	enff1, enff2 bool
 	rff1, rff2   bool
 	afterFirstRp bool
+
+	tracer Tracer
 }
 
 func NewOrderSelector() *OrderSelector {
@@ -42,6 +45,7 @@ func NewOrderSelector() *OrderSelector {
 	// Actually cross-wire digits, instead of trying to reset ring to -1 or 5 or
 	// somesuch, to model what would have happened had Ri not been stepped.
 	u.a = NewInput("os.A", func(j *Jack, val int) {
+		u.logPulse(j, 11, val)
 		if u.enff2 {
 			switch u.ring {
 			case 1:
@@ -54,6 +58,7 @@ func NewOrderSelector() *OrderSelector {
 		}
 	})
 	u.b = NewInput("os.B", func(j *Jack, val int) {
+		u.logPulse(j, 11, val)
 		if u.enff2 {
 			switch u.ring {
 			case 4:
@@ -65,20 +70,44 @@ func NewOrderSelector() *OrderSelector {
 			}
 		}
 	})
-	u.out = NewOutput("os.o", nil)
+	u.out = NewOutput("os.o", func(j *Jack, val int) {
+		u.logPulse(j, 11, val)
+	})
 	u.en = NewInput("os.i", func(j *Jack, val int) {
+		u.logPulse(j, 1, val)
 		u.enff1 = true
 	})
-	u.ringClear = NewInput("os.Ci", func(*Jack, int) {
+	u.ringClear = NewInput("os.Ci", func(j *Jack, val int) {
+		u.logPulse(j, 1, val)
 		u.ring = 0
 	})
-	u.ringIn = NewInput("os.Ri", func(*Jack, int) {
+	u.ringIn = NewInput("os.Ri", func(j *Jack, val int) {
+		u.logPulse(j, 1, val)
 		u.rff1 = true
 	})
-	u.ringOut = NewOutput("os.Ro", nil)
+	u.ringOut = NewOutput("os.Ro", func(j *Jack, val int) {
+		u.logPulse(j, 1, val)
+	})
+	u.a.TraceBits, u.b.TraceBits, u.out.TraceBits = 11, 11, 11
+	u.en.TraceBits, u.ringClear.TraceBits, u.ringIn.TraceBits, u.ringOut.TraceBits = 1, 1, 1, 1
 	return u
 }
 
+func (u *OrderSelector) logPulse(j *Jack, width, val int) {
+	if u.tracer != nil {
+		u.tracer.LogPulse(j.Name, width, int64(val))
+	}
+}
+
+// AttachTracer connects a trace logger.
+func (u *OrderSelector) AttachTracer(tracer Tracer) {
+	u.tracer = tracer
+	tracer.RegisterValueCallback(func() {
+		tracer.LogValue("os.ring", 3, int64(u.ring))
+		tracer.LogValue("os.enff", 1, BoolToInt64(u.enff2))
+	})
+}
+
 func (u *OrderSelector) Reset() {
 	u.ring = 0
 	u.enff1 = false
@@ -88,6 +117,44 @@ func (u *OrderSelector) Reset() {
 	u.afterFirstRp = false
 }
 
+type orderSelectorSnapshot struct {
+	Ring         int  `json:"ring"`
+	Enff1        bool `json:"enff1"`
+	Enff2        bool `json:"enff2"`
+	Rff1         bool `json:"rff1"`
+	Rff2         bool `json:"rff2"`
+	AfterFirstRp bool `json:"afterFirstRp"`
+}
+
+// Snapshot returns the dynamic state of the order selector.
+func (u *OrderSelector) Snapshot() json.RawMessage {
+	s := orderSelectorSnapshot{
+		Ring:         u.ring,
+		Enff1:        u.enff1,
+		Enff2:        u.enff2,
+		Rff1:         u.rff1,
+		Rff2:         u.rff2,
+		AfterFirstRp: u.afterFirstRp,
+	}
+	result, _ := json.Marshal(s)
+	return result
+}
+
+// Restore reloads dynamic state saved by Snapshot.
+func (u *OrderSelector) Restore(data json.RawMessage) error {
+	s := orderSelectorSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	u.ring = s.Ring
+	u.enff1 = s.Enff1
+	u.enff2 = s.Enff2
+	u.rff1 = s.Rff1
+	u.rff2 = s.Rff2
+	u.afterFirstRp = s.AfterFirstRp
+	return nil
+}
+
 func (u *OrderSelector) Clock(p Pulse) {
 	if p&Cpp != 0 {
 		if u.rff2 {
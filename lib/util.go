@@ -8,3 +8,9 @@ func MsToAddCycles(ms int64) int64 {
 type Cleared interface {
 	Clear()
 }
+
+// ProgramCleared units have their program flip-flops reset by the initiate
+// unit's clear.
+type ProgramCleared interface {
+	ClearPrograms()
+}
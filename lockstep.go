@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jeredw/eniacsim/lib/eniac"
+)
+
+// lockstep runs two configurations side by side and reports the first add
+// cycle where they compute something different.  Returns an exit status like
+// diff(1): 0 if the same, 1 if different and 2 on error.
+func lockstep(powerOn string, args []string) int {
+	fs := flag.NewFlagSet("lockstep", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s lockstep [options] a.e b.e\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	cycles := fs.Int("n", 1000000, "run for at most n add cycles")
+	cards := fs.String("cards", "", "card deck `file` to feed both machines")
+	printsOnly := fs.Bool("prints", false, "compare only printed cards, not when they print")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	var sides [2]*eniac.LockstepSide
+	for i, path := range fs.Args() {
+		m := eniac.NewMachine(eniac.MachineConn{Output: os.Stderr})
+		if err := m.PowerOn(powerOn); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if err := m.Load(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if *cards != "" {
+			m.Exec(os.Stderr, "f r "+*cards)
+		}
+		m.Units().Initiate.Io.Output = ioutil.Discard
+		m.Cycle().SetTestMode()
+		sides[i] = &eniac.LockstepSide{Name: path, Machine: m}
+	}
+	if !eniac.Lockstep(os.Stdout, sides[0], sides[1], *cycles, *printsOnly) {
+		return 1
+	}
+	return 0
+}
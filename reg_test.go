@@ -5,18 +5,50 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/jeredw/eniacsim/lib/eniac"
 )
 
 var update = flag.Bool("update", false, "update golden files")
+var powerOn = flag.String("power-on", "zero", "initial machine state, zero or random:seed")
+var coverage = flag.Bool("coverage", false, "write wiring coverage reports to .cov files")
 
-func runSimulator(path string, cycles int) ([]byte, error) {
-	cmd := exec.Command("./eniacsim", "-g", "-t", strconv.Itoa(cycles), path)
-	return cmd.Output()
+func runSimulator(path string, cycles int) (result []byte, err error) {
+	if *powerOn != "zero" {
+		// Programs left running from a random power on can conflict with the
+		// ones path starts in ways the units can't model, which is a failure
+		// of path under that seed rather than a reason to stop other tests.
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%s with -power-on %s: %v", path, *powerOn, r)
+			}
+		}()
+	}
+	var out bytes.Buffer
+	m := eniac.NewMachine(eniac.MachineConn{Output: &out})
+	if err := m.PowerOn(*powerOn); err != nil {
+		return nil, err
+	}
+	if err := m.Load(path); err != nil {
+		return nil, err
+	}
+	m.Cycle().SetTestMode()
+	if *coverage {
+		m.StartCoverage()
+	}
+	m.Run(cycles)
+	if *coverage {
+		var report bytes.Buffer
+		m.Exec(&report, "coverage")
+		if err := ioutil.WriteFile(path+".cov", report.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+	}
+	m.DumpAll(&out)
+	return out.Bytes(), nil
 }
 
 func TestGolden(t *testing.T) {
@@ -30,7 +62,9 @@ func TestGolden(t *testing.T) {
 			fmt.Printf("%s...", path)
 			out, err := runSimulator(path, 50000)
 			if err != nil {
+				fmt.Printf("fail\n")
 				t.Errorf("%s", err)
+				continue
 			}
 
 			golden, err := ioutil.ReadFile(path + ".out")
@@ -38,7 +72,10 @@ func TestGolden(t *testing.T) {
 				t.Fatalf("missing golden file for %s", path)
 			}
 			if bytes.Compare(out, golden) != 0 {
-				if *update {
+				if *powerOn != "zero" {
+					// Golden files hold the output from a cleared machine.
+					fmt.Printf("fail\n")
+				} else if *update {
 					fmt.Printf("update %s.out\n", path)
 					err = ioutil.WriteFile(path+".out", out, 0644)
 				} else {
@@ -55,3 +92,126 @@ func TestGolden(t *testing.T) {
 		}
 	}
 }
+
+func TestSnapshotRestore(t *testing.T) {
+	// Run part way, then finish on a fresh machine restored from a snapshot.
+	for _, name := range []string{"ag-ch8.e", "divtest.e", "fttest.e", "multtest.e", "sieve.e"} {
+		path := filepath.Join("testdata", name)
+		golden, err := ioutil.ReadFile(path + ".out")
+		if err != nil {
+			t.Fatalf("missing golden file for %s", path)
+		}
+		var out bytes.Buffer
+		m1 := eniac.NewMachine(eniac.MachineConn{Output: &out})
+		if err := m1.Load(path); err != nil {
+			t.Fatal(err)
+		}
+		m1.Cycle().SetTestMode()
+		m1.Run(5000)
+		m2 := eniac.NewMachine(eniac.MachineConn{Output: &out})
+		if err := m2.Load(path); err != nil {
+			t.Fatal(err)
+		}
+		m2.Cycle().SetTestMode()
+		if err := m2.Restore(m1.Snapshot()); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		m2.Run(50000 - 5000)
+		m2.DumpAll(&out)
+		if !bytes.Equal(out.Bytes(), golden) {
+			t.Errorf("%s differs after restore", name)
+		}
+	}
+}
+
+func TestBack(t *testing.T) {
+	path := filepath.Join("testdata", "ag-ch8.e")
+	golden, err := ioutil.ReadFile(path + ".out")
+	if err != nil {
+		t.Fatalf("missing golden file for %s", path)
+	}
+	var out bytes.Buffer
+	m := eniac.NewMachine(eniac.MachineConn{Output: &out})
+	if err := m.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	m.Cycle().SetTestMode()
+	m.Exec(&out, "back on")
+	m.Run(500)
+	var want bytes.Buffer
+	m.DumpAll(&want)
+	m.Run(6000)
+	printed := out.Len()
+	m.Exec(&out, "back 6000")
+	if out.Len() != printed {
+		t.Errorf("back printed %q", out.String()[printed:])
+	}
+	var got bytes.Buffer
+	m.DumpAll(&got)
+	if got.String() != want.String() {
+		t.Errorf("state after back differs:\n%s\nwant:\n%s", got.String(), want.String())
+	}
+	// Continuing from the rewound state should end up where a straight run
+	// does.
+	m.Run(50000 - 500)
+	got.Reset()
+	m.DumpAll(&got)
+	if !bytes.HasSuffix(golden, got.Bytes()) {
+		t.Errorf("final state after back differs from %s.out", path)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".e") {
+			continue
+		}
+		path := filepath.Join("testdata", file.Name())
+		var out bytes.Buffer
+		m1 := eniac.NewMachine(eniac.MachineConn{Output: &out})
+		if err := m1.Load(path); err != nil {
+			t.Fatal(err)
+		}
+		var config bytes.Buffer
+		if err := m1.WriteConfig(&config); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+
+		// Rebuild the machine from the written config, then replay any
+		// other commands (buttons, card decks) from the original file.
+		m2 := eniac.NewMachine(eniac.MachineConn{Output: &out})
+		for _, line := range strings.Split(config.String(), "\n") {
+			m2.Exec(&out, line)
+		}
+		original, _ := ioutil.ReadFile(path)
+		for _, line := range strings.Split(string(original), "\n") {
+			if f := strings.Fields(line); len(f) == 0 || (f[0] != "p" && f[0] != "s") {
+				m2.Exec(&out, line)
+			}
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: loading config printed %q", path, out.String())
+			continue
+		}
+		var config2 bytes.Buffer
+		m2.WriteConfig(&config2)
+		if config.String() != config2.String() {
+			t.Errorf("%s: config not stable:\n%s\nthen:\n%s", path, config.String(), config2.String())
+		}
+
+		golden, err := ioutil.ReadFile(path + ".out")
+		if err != nil {
+			t.Fatalf("missing golden file for %s", path)
+		}
+		m2.Cycle().SetTestMode()
+		m2.Run(50000)
+		m2.DumpAll(&out)
+		if !bytes.Equal(out.Bytes(), golden) {
+			t.Errorf("%s: output differs using written config", path)
+		}
+	}
+}
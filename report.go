@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jeredw/eniacsim/lib/eniac"
+)
+
+// finishRun writes the results of m's assertions to path, if set, as JUnit
+// XML if path ends in .xml and otherwise as JSON.  Returns the exit status for
+// the run: 0 if every assertion held, 1 if any failed and 2 on error.
+func finishRun(m *eniac.Machine, path, name string) int {
+	r := m.TestReport(name)
+	if path != "" {
+		if err := writeReport(r, path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if r.Failed != 0 {
+		return 1
+	}
+	return 0
+}
+
+func writeReport(r *eniac.TestReport, path string) error {
+	fd, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	bw := bufio.NewWriter(fd)
+	if strings.HasSuffix(path, ".xml") {
+		err = r.WriteJUnit(bw)
+	} else {
+		err = r.WriteJSON(bw)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
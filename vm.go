@@ -135,11 +135,11 @@ func (vm *VM) StepAhead(cycle int64) {
 
 // Exports a subset of eniac state to struct ENIAC which VM can read.
 func (vm *VM) exportEniacState() {
-	vm.eniac.cycles = C.ulonglong(cycle.AddCycle)
+	vm.eniac.cycles = C.ulonglong(machine.AddCycle())
 	vm.eniac.error_code = 0
 	vm.eniac.rollback = 0
 	for i := 0; i < 20; i++ {
-		value := u.Accumulator[i].Value()
+		value := machine.Units().Accumulator[i].Value()
 		vm.eniac.acc[i][0] = C.char(value[0])
 		for j := 0; j < 10; j++ {
 			vm.eniac.acc[i][1+j] = C.char(value[2+j])
@@ -150,7 +150,7 @@ func (vm *VM) exportEniacState() {
 		for t := 0; t < 3; t++ {
 			for r := 0; r < 104; r++ {
 				for d := 0; d < 14; d++ {
-					vm.eniac.ft[t][r][d] = C.int(u.Ft[t].GetDigit(r, d))
+					vm.eniac.ft[t][r][d] = C.int(machine.Units().Ft[t].GetDigit(r, d))
 				}
 			}
 		}
@@ -164,13 +164,13 @@ func (vm *VM) importEniacState() {
 	if vm.ErrorCode != 0 {
 		panic(fmt.Errorf("vm error state: %d\n", vm.ErrorCode))
 	}
-	cycle.AddCycle = int64(vm.eniac.cycles)
+	machine.Cycle().AddCycle = int64(vm.eniac.cycles)
 	for i := 0; i < 20; i++ {
 		if vm.eniac.acc[i][0] == 0 {
 			// Skip don't-care accumulators which the vm doesn't model
 			continue
 		}
-		u.Accumulator[i].SetValue(C.GoBytes(unsafe.Pointer(&vm.eniac.acc[i]), 11))
+		machine.Units().Accumulator[i].SetValue(C.GoBytes(unsafe.Pointer(&vm.eniac.acc[i]), 11))
 	}
 }
 
@@ -32,19 +32,19 @@ func streamEvents(w http.ResponseWriter, req *http.Request) {
 
 	status := make(map[string]json.RawMessage)
 	for {
-		status["initiate"], _ = json.Marshal(u.Initiate.Stat())
-		status["cycling"], _ = json.Marshal(cycle.Stat())
-		status["mp"] = u.Mp.State()
-		ftState := []json.RawMessage{u.Ft[0].State(), u.Ft[1].State(), u.Ft[2].State()}
+		status["initiate"], _ = json.Marshal(machine.Units().Initiate.Stat())
+		status["cycling"], _ = json.Marshal(machine.Cycle().Stat())
+		status["mp"] = machine.Units().Mp.State()
+		ftState := []json.RawMessage{machine.Units().Ft[0].State(), machine.Units().Ft[1].State(), machine.Units().Ft[2].State()}
 		status["ft"], _ = json.Marshal(ftState)
 		accState := [20]json.RawMessage{}
-		for i := range u.Accumulator {
-			accState[i] = u.Accumulator[i].State()
+		for i := range machine.Units().Accumulator {
+			accState[i] = machine.Units().Accumulator[i].State()
 		}
 		status["acc"], _ = json.Marshal(accState)
-		status["div"] = u.Divsr.State()
-		status["mult"] = u.Multiplier.State()
-		status["constant"], _ = json.Marshal(u.Constant.Stat())
+		status["div"] = machine.Units().Divsr.State()
+		status["mult"] = machine.Units().Multiplier.State()
+		status["constant"], _ = json.Marshal(machine.Units().Constant.Stat())
 		message, _ := json.Marshal(status)
 		fmt.Fprintf(w, "data: %s\n\n", message)
 		time.Sleep(100 * time.Millisecond)
@@ -77,7 +77,7 @@ func postCommand(w http.ResponseWriter, req *http.Request) {
 	respData := commandResponse{Outputs: make([]string, 0, len(reqData.Commands))}
 	for i := range reqData.Commands {
 		var buf bytes.Buffer
-		doCommand(&buf, reqData.Commands[i])
+		machine.Exec(&buf, reqData.Commands[i])
 		respData.Outputs = append(respData.Outputs, buf.String())
 	}
 